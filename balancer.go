@@ -0,0 +1,88 @@
+package reiner
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer 是 Slave 資料庫的負載平衡器，這會決定每次讀取時該使用哪一個 Slave。
+// 實作必須能夠安全地在多個 Goroutine 中同時使用。
+type Balancer interface {
+	// Next 會從傳入的可用 Slave 中選出一個，並回傳它在切片中的索引。
+	// 傳入的切片永遠至少會有一個 Slave。
+	Next(replicas []Replica) int
+}
+
+// Replica 是一個能夠被負載平衡器選擇的 Slave 資料庫。
+type Replica struct {
+	// DataSourceName 是這個 Slave 的資料來源名稱。
+	DataSourceName string
+	// Weight 是這個 Slave 的權重，未指定時為 `1`。
+	Weight int
+	// InFlight 是這個 Slave 目前正在執行中的指令數量。
+	InFlight int64
+}
+
+// RoundRobinBalancer 會輪詢每個 Slave，這是預設的負載平衡器。
+type RoundRobinBalancer struct {
+	counter uint64
+}
+
+// Next 會選出下一個輪到的 Slave。
+func (r *RoundRobinBalancer) Next(replicas []Replica) int {
+	return int((atomic.AddUint64(&r.counter, 1) - 1) % uint64(len(replicas)))
+}
+
+// WeightedBalancer 會依照每個 Slave 的權重來平滑地輪詢，權重越高的 Slave 會被選到越多次。
+// 權重可以在 `New` 時透過 `map[string]int` 傳入。
+type WeightedBalancer struct {
+	lock    sync.Mutex
+	current map[string]int
+}
+
+// Next 會以平滑加權輪詢（Smooth Weighted Round Robin）選出一個 Slave。
+func (w *WeightedBalancer) Next(replicas []Replica) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.current == nil {
+		w.current = make(map[string]int)
+	}
+	var total int
+	index := 0
+	for i, v := range replicas {
+		weight := v.Weight
+		if weight < 1 {
+			weight = 1
+		}
+		total += weight
+		w.current[v.DataSourceName] += weight
+		if w.current[v.DataSourceName] > w.current[replicas[index].DataSourceName] {
+			index = i
+		}
+	}
+	w.current[replicas[index].DataSourceName] -= total
+	return index
+}
+
+// RandomBalancer 會隨機選擇一個 Slave。
+type RandomBalancer struct{}
+
+// Next 會隨機選出一個 Slave。
+func (RandomBalancer) Next(replicas []Replica) int {
+	return rand.Intn(len(replicas))
+}
+
+// LeastInFlightBalancer 會選擇目前正在執行最少指令的 Slave，數量相同時會選擇較前面的 Slave。
+type LeastInFlightBalancer struct{}
+
+// Next 會選出正在執行最少指令的 Slave。
+func (LeastInFlightBalancer) Next(replicas []Replica) int {
+	index := 0
+	for i, v := range replicas {
+		if v.InFlight < replicas[index].InFlight {
+			index = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,56 @@
+package reiner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancerRoundRobin(t *testing.T) {
+	assert := assert.New(t)
+	b := &RoundRobinBalancer{}
+	replicas := []Replica{{DataSourceName: "a"}, {DataSourceName: "b"}, {DataSourceName: "c"}}
+	var picked []int
+	for i := 0; i < 6; i++ {
+		picked = append(picked, b.Next(replicas))
+	}
+	assert.Equal([]int{0, 1, 2, 0, 1, 2}, picked)
+}
+
+func TestBalancerWeighted(t *testing.T) {
+	assert := assert.New(t)
+	b := &WeightedBalancer{}
+	replicas := []Replica{{DataSourceName: "a", Weight: 5}, {DataSourceName: "b", Weight: 1}, {DataSourceName: "c", Weight: 1}}
+	var picked []int
+	for i := 0; i < 7; i++ {
+		picked = append(picked, b.Next(replicas))
+	}
+	assert.Equal([]int{0, 0, 1, 0, 2, 0, 0}, picked)
+}
+
+func TestBalancerRandom(t *testing.T) {
+	assert := assert.New(t)
+	replicas := []Replica{{DataSourceName: "a"}, {DataSourceName: "b"}}
+	for i := 0; i < 10; i++ {
+		index := RandomBalancer{}.Next(replicas)
+		assert.True(index >= 0 && index < len(replicas))
+	}
+}
+
+func TestBalancerLeastInFlight(t *testing.T) {
+	assert := assert.New(t)
+	replicas := []Replica{{DataSourceName: "a", InFlight: 3}, {DataSourceName: "b", InFlight: 1}, {DataSourceName: "c", InFlight: 1}}
+	assert.Equal(1, LeastInFlightBalancer{}.Next(replicas))
+}
+
+func TestBalancerGetSlave(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave", "root:root@/slave2")
+	d.setBalancer(LeastInFlightBalancer{})
+
+	conn := d.getSlave().acquire()
+	assert.Equal(d.slaves[0], conn)
+	assert.Equal(d.slaves[1], d.getSlave())
+	conn.release()
+	assert.Equal(d.slaves[0], d.getSlave())
+}
@@ -142,7 +142,7 @@ func structTraverse(m map[string][]int, t reflect.Type, head []int) {
 				// unexported
 				continue
 			}
-			tag := field.Tag.Get("db")
+			tag, _ := parseTag(field.Tag.Get("db"))
 			if tag == "-" {
 				// ignore
 				continue
@@ -152,10 +152,12 @@ func structTraverse(m map[string][]int, t reflect.Type, head []int) {
 				//tag = camelCaseToSnakeCase(field.Name)
 				tag = field.Name
 			}
+			// 複製一份索引，避免相鄰的欄位共用同一個底層陣列而互相覆寫。
+			index := append(append([]int{}, head...), i)
 			if _, ok := m[tag]; !ok {
-				m[tag] = append(head, i)
+				m[tag] = index
 			}
-			structTraverse(m, field.Type, append(head, i))
+			structTraverse(m, field.Type, index)
 		}
 	}
 }
@@ -0,0 +1,214 @@
+package reiner
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// BreakerState 是斷路器的狀態。
+type BreakerState int
+
+const (
+	// BreakerClosed 表示連線正常，所有的指令都能夠使用這個連線。
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 表示連線持續失敗，在冷卻時間結束之前這個 Slave 都不會被用來讀取資料。
+	BreakerOpen
+	// BreakerHalfOpen 表示冷卻時間已經結束，正在以單個指令試探連線是否已經恢復。
+	BreakerHalfOpen
+)
+
+// String 會回傳斷路器狀態的名稱。
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig 是每個連線的斷路器設置。只有連線層級的錯誤（例如：逾時、連線中斷）才會被視為失敗，
+// SQL 語法錯誤或重複鍵等資料庫回傳的錯誤則不會。
+type CircuitBreakerConfig struct {
+	// Failures 是連續失敗幾次後就會斷路，零值表示不依照連續失敗次數斷路。
+	Failures int
+	// ErrorRate 是最近 `Window` 次執行中的失敗比例（`0` 到 `1`）達到多少時就會斷路，零值表示不依照失敗比例斷路。
+	ErrorRate float64
+	// Window 是計算失敗比例時所使用的最近執行次數，執行次數不足時不會依照失敗比例斷路（預設為：`20`）。
+	Window int
+	// Cooldown 是斷路後需要等待多久才會以單個指令試探連線是否已經恢復。
+	Cooldown time.Duration
+}
+
+// breaker 是單個連線的斷路器。
+type breaker struct {
+	config CircuitBreakerConfig
+	state  BreakerState
+	// failures 是目前的連續失敗次數。
+	failures int
+	// results 是最近 `Window` 次執行的結果，`true` 表示失敗。
+	results []bool
+	// next 是下一次執行結果在 `results` 中的位置。
+	next int
+	// openedAt 是最後一次斷路的時間。
+	openedAt time.Time
+	// probedAt 是半開狀態中試探指令開始的時間，沒有正在試探時會是零值。
+	probedAt time.Time
+	lock     sync.Mutex
+}
+
+// newBreaker 會依照設置建立一個斷路器，沒有設置時會回傳 nil 表示停用。
+func newBreaker(config *CircuitBreakerConfig) *breaker {
+	if config == nil {
+		return nil
+	}
+	if config.Window < 1 {
+		config.Window = 20
+	}
+	return &breaker{config: *config}
+}
+
+// ready 會回傳這個連線目前是否能夠被選擇，斷路中的連線在冷卻時間結束後會允許一個試探指令。
+// 如果試探指令在冷卻時間內都沒有回報結果，則會允許下一個試探指令。這並不會取得試探的資格，選擇連線時必須再透過 `tryAcquire` 取得。
+func (b *breaker) ready() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.probeable()
+}
+
+// tryAcquire 會在連線被選擇時呼叫，並回傳這個連線是否能夠使用。如果斷路器能夠試探連線，則會轉為半開狀態並將這個指令作為試探指令，
+// 因為檢查與取得試探資格是在同一次鎖定中完成的，所以同時選擇這個連線的其他指令都會得到 `false`。
+func (b *breaker) tryAcquire() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.probeable() {
+		return false
+	}
+	if b.state != BreakerClosed {
+		b.state = BreakerHalfOpen
+		b.probedAt = time.Now()
+	}
+	return true
+}
+
+// probeable 會回傳斷路器目前是否允許指令通過，呼叫者必須持有鎖。
+func (b *breaker) probeable() bool {
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= b.config.Cooldown
+	case BreakerHalfOpen:
+		return b.probedAt.IsZero() || time.Since(b.probedAt) >= b.config.Cooldown
+	}
+	return true
+}
+
+// record 會依照執行的結果更新斷路器的狀態。半開狀態中試探成功會關閉斷路器，失敗則會重新斷路。
+// 主要資料庫即使斷路也仍然會被使用而不會經過 `tryAcquire`，所以冷卻時間結束後的第一個結果也會被視為試探的結果。
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	failed := isConnectionFailure(err)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.Cooldown {
+			return
+		}
+		fallthrough
+	case BreakerHalfOpen:
+		if failed {
+			b.trip()
+		} else {
+			b.reset()
+		}
+		return
+	}
+	if b.results == nil {
+		b.results = make([]bool, 0, b.config.Window)
+	}
+	if len(b.results) < b.config.Window {
+		b.results = append(b.results, failed)
+	} else {
+		b.results[b.next] = failed
+	}
+	b.next = (b.next + 1) % b.config.Window
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.config.Failures > 0 && b.failures >= b.config.Failures {
+		b.trip()
+		return
+	}
+	if b.config.ErrorRate > 0 && len(b.results) == b.config.Window {
+		var count int
+		for _, v := range b.results {
+			if v {
+				count++
+			}
+		}
+		if float64(count)/float64(b.config.Window) >= b.config.ErrorRate {
+			b.trip()
+		}
+	}
+}
+
+// trip 會讓斷路器斷路並開始計算冷卻時間。
+func (b *breaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.probedAt = time.Time{}
+}
+
+// reset 會關閉斷路器並清除所有的執行結果。
+func (b *breaker) reset() {
+	b.state = BreakerClosed
+	b.failures = 0
+	b.results = b.results[:0]
+	b.next = 0
+	b.probedAt = time.Time{}
+}
+
+// currentState 會回傳斷路器目前的狀態，停用時會是 `BreakerClosed`。
+func (b *breaker) currentState() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// isConnectionFailure 會回傳錯誤是否為連線層級的失敗，例如逾時、無法連線或連線中斷。
+func isConnectionFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return strings.Contains(err.Error(), "server has gone away")
+}
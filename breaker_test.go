@@ -0,0 +1,137 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerFailures(t *testing.T) {
+	assert := assert.New(t)
+	b := newBreaker(&CircuitBreakerConfig{Failures: 2, Cooldown: 20 * time.Millisecond})
+
+	// 資料庫回傳的錯誤不會被視為連線失敗。
+	b.record(errors.New("Error 1064: You have an error in your SQL syntax"))
+	b.record(errors.New("Error 1064: You have an error in your SQL syntax"))
+	assert.Equal(BreakerClosed, b.currentState())
+
+	b.record(context.DeadlineExceeded)
+	b.record(nil)
+	b.record(context.DeadlineExceeded)
+	assert.Equal(BreakerClosed, b.currentState())
+	b.record(context.DeadlineExceeded)
+	assert.Equal(BreakerOpen, b.currentState())
+	assert.False(b.ready())
+
+	// 冷卻時間結束後會允許單個試探指令，試探失敗會重新斷路。
+	time.Sleep(30 * time.Millisecond)
+	assert.True(b.ready())
+	assert.True(b.tryAcquire())
+	assert.Equal(BreakerHalfOpen, b.currentState())
+	assert.False(b.ready())
+	b.record(context.DeadlineExceeded)
+	assert.Equal(BreakerOpen, b.currentState())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.True(b.tryAcquire())
+	b.record(nil)
+	assert.Equal(BreakerClosed, b.currentState())
+	assert.True(b.ready())
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	assert := assert.New(t)
+	b := newBreaker(&CircuitBreakerConfig{ErrorRate: 0.5, Window: 4, Cooldown: time.Second})
+
+	b.record(context.DeadlineExceeded)
+	b.record(nil)
+	b.record(nil)
+	assert.Equal(BreakerClosed, b.currentState())
+	b.record(context.DeadlineExceeded)
+	assert.Equal(BreakerOpen, b.currentState())
+}
+
+func TestBreakerGetSlave(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave", "root:root@/slave2")
+	for _, v := range d.slaves {
+		v.breaker = newBreaker(&CircuitBreakerConfig{Failures: 1, Cooldown: time.Second})
+	}
+
+	d.slaves[0].breaker.record(context.DeadlineExceeded)
+	for i := 0; i < 4; i++ {
+		assert.Equal(d.slaves[1], d.getSlave())
+	}
+	assert.Equal(BreakerOpen, d.status()[1].Breaker)
+	assert.Equal(BreakerClosed, d.status()[2].Breaker)
+
+	d.slaves[1].breaker.record(context.DeadlineExceeded)
+	assert.Equal(d.master, d.getSlave())
+}
+
+func TestBreakerMasterRecovery(t *testing.T) {
+	assert := assert.New(t)
+	d, err := newDatabase(Config{Dialect: testDialect{}, Master: "master", CircuitBreaker: &CircuitBreakerConfig{Failures: 1, Cooldown: 20 * time.Millisecond}})
+	assert.NoError(err)
+	d.getMaster().breaker.record(context.DeadlineExceeded)
+	assert.Equal(BreakerOpen, d.status()[0].Breaker)
+
+	// 主要資料庫不會被略過，冷卻時間結束後成功執行的指令就會關閉斷路器。
+	time.Sleep(30 * time.Millisecond)
+	_, err = d.exec(context.Background(), "UPDATE Users SET Username = ?", "YamiOdymel")
+	assert.NoError(err)
+	assert.Equal(BreakerClosed, d.status()[0].Breaker)
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	assert := assert.New(t)
+	b := newBreaker(&CircuitBreakerConfig{Failures: 1, Cooldown: 20 * time.Millisecond})
+	b.record(context.DeadlineExceeded)
+	time.Sleep(30 * time.Millisecond)
+
+	// 同時選擇這個連線的指令中只有一個能夠成為試探指令。
+	var acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.tryAcquire() {
+				atomic.AddInt32(&acquired, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), acquired)
+	assert.Equal(BreakerHalfOpen, b.currentState())
+
+	d := newTestDatabase("root:root@/slave", "root:root@/slave2")
+	d.slaves[0].breaker = newBreaker(&CircuitBreakerConfig{Failures: 1, Cooldown: 20 * time.Millisecond})
+	d.slaves[0].breaker.record(context.DeadlineExceeded)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(d.slaves[0], d.getSlave())
+	assert.Equal(d.slaves[1], d.getSlave())
+	assert.Equal(d.slaves[1], d.getSlave())
+}
+
+func TestBreakerTransactionProbe(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master", Slaves: []string{"slave"}, CircuitBreaker: &CircuitBreakerConfig{Failures: 1, Cooldown: 20 * time.Millisecond}})
+	assert.NoError(err)
+	slave := b.db.getSlaves()[0]
+	slave.breaker.record(context.DeadlineExceeded)
+	time.Sleep(30 * time.Millisecond)
+
+	// 作為試探指令的唯讀交易成功開始後就會關閉斷路器。
+	tx, err := b.BeginTx(&sql.TxOptions{ReadOnly: true})
+	assert.NoError(err)
+	assert.Equal("slave", tx.db.getMaster().dataSourceName)
+	assert.Equal(BreakerClosed, slave.breaker.currentState())
+	assert.NoError(tx.Rollback())
+}
@@ -1,10 +1,13 @@
 package reiner
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,12 +18,23 @@ import (
 var (
 	// ErrInvalidPointer 是會在資料的映射目的地為 nil 指標時所發生的錯誤。
 	ErrInvalidPointer = errors.New("reiner: the destination of the result is an invalid pointer")
-	// ErrIncorrectDataType 是個會在插入、更新資料時傳入非 `map[string]interface` 資料型態參數時所發生的錯誤。
-	ErrIncorrectDataType = errors.New("reiner: the data type must be a `map[string]interface`")
+	// ErrIncorrectDataType 是個會在插入、更新資料時傳入非 `map[string]interface`、結構體或是它們的切片時所發生的錯誤。
+	ErrIncorrectDataType = errors.New("reiner: the data type must be a `map[string]interface` or a struct")
 	// ErrUnbegunTransaction 會在執行尚未透過 `Begin` 初始化的交易時所發生的錯誤。
 	ErrUnbegunTransaction = errors.New("reiner: calling the transaction function without `Begin()`")
 	// ErrNoTable 是個會在未指定資料表格時所發生的錯誤。
 	ErrNoTable = errors.New("reiner: no table was specified")
+	// ErrReadOnly 是會在主要資料庫無法連線而處於唯讀模式時執行寫入指令或交易所發生的錯誤。
+	ErrReadOnly = errors.New("reiner: the master is unavailable and the database is in read-only mode")
+	// ErrShutdown 是會在資料庫透過 `Shutdown` 關閉後執行指令或開始交易時所發生的錯誤。
+	ErrShutdown = errors.New("reiner: the database is shutting down")
+	// ErrUnsupportedDefault 是會在不支援 `DEFAULT` 的方言（例如：SQLite）中，以 `Insert` 或 `Replace` 插入多筆
+	// `omitempty` 欄位只有部分是零值的結構體時所發生的錯誤，`InsertMulti` 則會自動將這些資料分批插入。
+	ErrUnsupportedDefault = errors.New("reiner: the dialect does not support DEFAULT in VALUES, use InsertMulti instead")
+	// ErrUnsupportedLock 是會在不支援 `LOCK TABLES` 的方言（例如：SQLite、PostgreSQL）中呼叫 `Lock` 或 `Unlock` 時所發生的錯誤。
+	ErrUnsupportedLock = errors.New("reiner: the dialect does not support LOCK TABLES")
+	// ErrXATransaction 是會在 XA 交易中呼叫 `Begin` 開始一般交易時所發生的錯誤。
+	ErrXATransaction = errors.New("reiner: cannot begin a transaction inside an XA transaction")
 )
 
 // Function 重現了一個像 `SHA(?)` 或 `NOW()` 的資料庫函式。
@@ -49,22 +63,49 @@ type join struct {
 	conditions []condition
 }
 
+// compound 是一個以 `UNION`、`UNION ALL`、`INTERSECT` 或 `EXCEPT` 組合的子指令。
+type compound struct {
+	operator string
+	query    *SubQuery
+}
+
+// commonTable 是一個以 `WITH` 定義的公用資料表（CTE）。
+type commonTable struct {
+	name    string
+	columns []string
+	query   *SubQuery
+	// recursive 是遞迴公用資料表中參照自己的子指令，它會以 `UNION ALL` 接在 `query` 之後，非遞迴的公用資料表會是 nil。
+	recursive *SubQuery
+}
+
 // Trace 是個已執行的 SQL 指令蹤跡、堆疊資料。
 type Trace struct {
 	Query    string
 	Duration time.Duration
 	Stacks   []map[string]interface{}
 	Error    error
+	// Attempt 是這次執行是第幾次嘗試，重試的時候會大於 `1`。
+	Attempt int
 }
 
 // Builder 是個資料庫的 SQL 指令建置系統，同時也帶有資料庫的連線資料。
 type Builder struct {
 	db *DB
+	// ctx 是執行 SQL 指令時所使用的上下文，未指定時會是 nil 並以 `context.Background()` 替代。
+	ctx context.Context
+	// conn 是最後一次執行指令時所使用的連線，這會被用來回報指標。
+	conn *connection
+	// session 是讀寫一致的工作階段，沒有透過 `StickyMaster` 啟用時會是 nil。
+	session *session
 	// executable 表示是否該執行建置後的指令，當沒有連線的時候這會是 `false`。
 	// 這表示僅用於建置 SQL 指令，而不是執行它。
 	executable bool
 	// alias 是作為子指令時所帶有的別名，這會用在子指令資料表格的加入上。
 	alias string
+	// dialect 是建置 SQL 指令時所使用的方言。
+	dialect Dialect
+	// subQuery 表示這是否為子指令，子指令會保留 `?` 佔位符號，直到被放入主要指令後才一起轉換。
+	subQuery bool
 	// destination 呈現了資料的映射目的地指針。
 	destination        interface{}
 	tableName          []string
@@ -74,15 +115,25 @@ type Builder struct {
 	joins              map[string]*join
 	joinOrder          []string
 	onDuplicateColumns []string
+	conflictColumns    []string
 	lastInsertIDColumn string
+	compounds          []compound
+	commonTables       []commonTable
 	limit              []int
 	orders             []order
 	groupBy            []string
 	lockMethod         string
 	tracing            bool
+	useMaster          bool
+	retryPolicy        RetryPolicy
+	chunk              ChunkOptions
 	query              string
 	params             []interface{}
 	count              int
+	// bounded 表示最後建置的指令是否帶有排序或筆數限制，作為組合指令的一部分時必須以括號包覆。
+	bounded bool
+	// countTotal 表示是否要另外以 `COUNT(*)` 查詢取得總筆數，這會用在不支援 `SQL_CALC_FOUND_ROWS` 的方言上。
+	countTotal bool
 
 	// Timestamp 是時間戳的相關輔助函式。
 	Timestamp *Timestamp
@@ -102,11 +153,13 @@ type Builder struct {
 	LastParams []interface{}
 	// LastResult 是最後執行時的 `sql.Result` 資料。
 	LastResult sql.Result
+	// Chunks 是最後一次 `InsertMulti` 每批插入的結果。
+	Chunks []ChunkResult
 }
 
 // newBuilder 會基於傳入的資料庫連線來建立一個新的 SQL 指令建置系統。
 func newBuilder(db *DB) *Builder {
-	return &Builder{executable: true, db: db, Timestamp: &Timestamp{}, PageLimit: 20, joins: make(map[string]*join)}
+	return &Builder{executable: true, db: db, dialect: db.dialect, Timestamp: &Timestamp{}, PageLimit: 20, joins: make(map[string]*join)}
 }
 
 // clone 會複製資料庫建置函式來避免多個 Goroutine 編輯同個資料庫建置函式指標建構體。
@@ -128,14 +181,21 @@ func (b *Builder) cleanAfter() {
 	b.tableName = []string{}
 	b.params = []interface{}{}
 	b.onDuplicateColumns = []string{}
+	b.lastInsertIDColumn = ""
+	b.conflictColumns = []string{}
 	b.groupBy = []string{}
 	b.joins = map[string]*join{}
 	b.joinOrder = []string{}
+	b.compounds = []compound{}
+	b.commonTables = []commonTable{}
 	b.orders = []order{}
 	b.conditions = []condition{}
 	b.havingConditions = []condition{}
 	b.limit = []int{}
 	b.destination = nil
+	b.useMaster = false
+	b.chunk = ChunkOptions{}
+	b.countTotal = false
 }
 
 // cleanBefore 會在 SQL 指令建置之前清除以往的資料，
@@ -145,16 +205,26 @@ func (b *Builder) cleanBefore() {
 	b.TotalPage = 0
 	b.LastInsertID = 0
 	b.LastResult = nil
+	b.Chunks = nil
 	b.LastParams = []interface{}{}
 	b.count = 0
 }
 
+// context 會回傳執行 SQL 指令時所使用的上下文。
+func (b *Builder) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
 //=======================================================
 // 保存函式
 //=======================================================
 
 // saveTrace 會取得、紀錄呼叫函式的名稱，並且計算執行時間然後將其保存於蹤跡資訊中。
-func (b *Builder) saveTrace(err error, query string, startedAt time.Time) {
+// 重試時每次的執行都會各自被保存，並透過 `attempt` 區分這是第幾次執行。
+func (b *Builder) saveTrace(err error, query string, startedAt time.Time, attempt int) {
 	if !b.tracing {
 		return
 	}
@@ -176,6 +246,7 @@ func (b *Builder) saveTrace(err error, query string, startedAt time.Time) {
 		Duration: time.Since(startedAt),
 		Stacks:   stacks,
 		Error:    err,
+		Attempt:  attempt,
 	})
 }
 
@@ -231,6 +302,20 @@ func (b *Builder) saveCondition(typ, connector string, args ...interface{}) {
 	}
 }
 
+// saveCompound 會保存以指定方式組合的子指令。
+func (b *Builder) saveCompound(operator string, subQueries ...*SubQuery) {
+	compounds := append([]compound{}, b.compounds...)
+	for _, v := range subQueries {
+		compounds = append(compounds, compound{operator: operator, query: v})
+	}
+	b.compounds = compounds
+}
+
+// saveCommonTable 會保存以 `WITH` 定義的公用資料表。
+func (b *Builder) saveCommonTable(table commonTable) {
+	b.commonTables = append(append([]commonTable{}, b.commonTables...), table)
+}
+
 //=======================================================
 // 參數函式
 //=======================================================
@@ -329,25 +414,45 @@ func (b *Builder) buildUpdate(data interface{}) (query string, err error) {
 	beforeOptions, _ := b.buildQueryOptions()
 	query = fmt.Sprintf("UPDATE %s%s SET ", beforeOptions, b.tableName[0])
 
-	switch realData := data.(type) {
-	case map[string]interface{}:
-		for column, value := range realData {
-			set += fmt.Sprintf("%s = %s, ", column, b.bindParam(value))
-		}
+	columns, values, err := updateColumns(data)
+	if err != nil {
+		return
+	}
+	for k, column := range columns {
+		set += fmt.Sprintf("%s = %s, ", column, b.bindParam(values[k]))
 	}
 	query += fmt.Sprintf("%s ", trim(set))
 	return
 }
 
-// buildLimit 會建置 `LIMIT` 的 SQL 指令。
+// updateColumns 會將更新的資料轉換成欄位與相對應的值，資料可以是 `map[string]interface{}` 或是結構體。
+// `map` 的欄位會依照名稱排序，這樣每次產生的 SQL 指令才會相同而能夠被快取。
+func updateColumns(data interface{}) (columns []string, values []interface{}, err error) {
+	if realData, ok := data.(map[string]interface{}); ok {
+		columns = sortedKeys(realData)
+		for _, column := range columns {
+			values = append(values, realData[column])
+		}
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		err = ErrIncorrectDataType
+		return
+	}
+	columns, values = structUpdateColumns(v)
+	return
+}
+
+// buildLimit 會依照方言建置 `LIMIT` 的 SQL 指令。
 func (b *Builder) buildLimit() (query string) {
 	switch len(b.limit) {
 	case 0:
 		return
 	case 1:
-		query = fmt.Sprintf("LIMIT %d ", b.limit[0])
+		query = fmt.Sprintf("%s ", b.dialect.Limit(-1, b.limit[0]))
 	case 2:
-		query = fmt.Sprintf("LIMIT %d, %d ", b.limit[0], b.limit[1])
+		query = fmt.Sprintf("%s ", b.dialect.Limit(b.limit[0], b.limit[1]))
 	}
 	return
 }
@@ -478,17 +583,67 @@ func (b *Builder) buildQueryOptions() (before string, after string) {
 
 // buildQuery 會將所有建置工作串連起來並且依序執行來建置整個可用的 SQL 指令。
 func (b *Builder) buildQuery() {
+	b.bounded = len(b.orders) > 0 || len(b.limit) > 0
 	b.query += b.buildDuplicate()
 	b.query += b.buildJoin()
 	b.query += b.buildWhere("WHERE")
+	b.query += b.buildGroupBy()
 	b.query += b.buildWhere("HAVING")
+	// 排序與筆數限制會套用在組合後的結果上。
+	b.query += b.buildCompounds()
 	b.query += b.buildOrderBy()
-	b.query += b.buildGroupBy()
 	b.query += b.buildLimit()
 
 	_, afterOptions := b.buildQueryOptions()
 	b.query += afterOptions
 	b.query = strings.TrimSpace(b.query)
+	// `WITH` 的參數位於整個指令的最前面，所以要放在其他已綁定的參數之前。
+	if len(b.commonTables) > 0 {
+		params := b.params
+		b.params = []interface{}{}
+		b.query = b.buildWith() + b.query
+		b.params = append(b.params, params...)
+	}
+	// 子指令的佔位符號會在被放入主要指令之後才一起依序轉換。
+	if !b.subQuery {
+		b.query = rebind(b.dialect, b.query)
+	}
+}
+
+// buildWith 會建置 `WITH` 或 `WITH RECURSIVE` 的 SQL 指令，只要其中一個公用資料表是遞迴的就會使用 `WITH RECURSIVE`。
+func (b *Builder) buildWith() (query string) {
+	keyword := "WITH"
+	for _, v := range b.commonTables {
+		if v.recursive != nil {
+			keyword = "WITH RECURSIVE"
+		}
+	}
+	for _, v := range b.commonTables {
+		query += v.name
+		if len(v.columns) > 0 {
+			query += fmt.Sprintf(" (%s)", strings.Join(v.columns, ", "))
+		}
+		if v.recursive != nil {
+			query += fmt.Sprintf(" AS (%s UNION ALL %s), ", b.bindParam(v.query, false), b.bindParam(v.recursive, false))
+		} else {
+			query += fmt.Sprintf(" AS %s, ", b.bindParam(v.query))
+		}
+	}
+	query = fmt.Sprintf("%s %s ", keyword, trim(query))
+	return
+}
+
+// buildCompounds 會建置 `UNION`、`UNION ALL`、`INTERSECT` 與 `EXCEPT` 的 SQL 指令，
+// 帶有排序或筆數限制的子指令會依照方言被包覆（例如：括號），這樣它們才不會被套用到組合後的結果上。
+func (b *Builder) buildCompounds() (query string) {
+	for _, v := range b.compounds {
+		param := b.bindParam(v.query, false)
+		if v.query.builder.bounded {
+			param = b.dialect.WrapCompound(param)
+		}
+		query += fmt.Sprintf("%s %s ", v.operator, param)
+	}
+	return
 }
 
 // buildOrderBy 會基於現有的排序資料來建置 `ORDERY BY` 的 SQL 指令。
@@ -527,19 +682,12 @@ func (b *Builder) buildGroupBy() (query string) {
 	return
 }
 
-// buildDuplicate 會建置 `ON DUPLICATE KEY UPDATE` 的 SQL 指令。
+// buildDuplicate 會依照方言建置 `ON DUPLICATE KEY UPDATE` 或 `ON CONFLICT` 的 SQL 指令。
 func (b *Builder) buildDuplicate() (query string) {
 	if len(b.onDuplicateColumns) == 0 {
 		return
 	}
-	query += "ON DUPLICATE KEY UPDATE "
-	if b.lastInsertIDColumn != "" {
-		query += fmt.Sprintf("%s=LAST_INSERT_ID(%s), ", b.lastInsertIDColumn, b.lastInsertIDColumn)
-	}
-	for _, v := range b.onDuplicateColumns {
-		query += fmt.Sprintf("%s = VALUES(%s), ", v, v)
-	}
-	query = trim(query)
+	query = b.dialect.Upsert(b.conflictColumns, b.onDuplicateColumns, b.lastInsertIDColumn)
 	return
 }
 
@@ -549,37 +697,70 @@ func (b *Builder) buildInsert(operator string, data interface{}) (query string,
 		err = ErrNoTable
 		return
 	}
-	var columns, values string
+	var values string
 	beforeOptions, _ := b.buildQueryOptions()
 
-	// 會基於資料型態建置不同的指令。
+	columns, rows, err := insertRows(data)
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		var currentValues string
+		for _, value := range row {
+			if d, ok := value.(defaultValue); ok {
+				if value, err = b.defaultValue(d); err != nil {
+					return
+				}
+			}
+			currentValues += fmt.Sprintf("%s, ", b.bindParam(value))
+		}
+		values += fmt.Sprintf("(%s), ", trim(currentValues))
+	}
+	values = trim(values)
+	query = fmt.Sprintf("%s %sINTO %s (%s) VALUES %s ", operator, beforeOptions, b.tableName[0], strings.Join(columns, ", "), values)
+	return
+}
+
+// defaultValue 會依照方言將使用欄位預設值的參數轉換成 `DEFAULT` 或是 NULL。
+func (b *Builder) defaultValue(d defaultValue) (interface{}, error) {
+	if keyword := b.dialect.DefaultValue(); keyword != "" {
+		return Function{query: keyword}, nil
+	}
+	if d.autoIncrement {
+		return nil, nil
+	}
+	return nil, ErrUnsupportedDefault
+}
+
+// insertRows 會將插入的資料轉換成欄位名稱與每列的值，資料可以是 `map[string]interface{}`、結構體或是它們的切片。
+// `map` 的欄位會依照名稱排序，結構體的欄位則會依照宣告順序。
+func insertRows(data interface{}) (columns []string, rows [][]interface{}, err error) {
 	switch realData := data.(type) {
 	case map[string]interface{}:
-		for column, value := range realData {
-			columns += fmt.Sprintf("%s, ", column)
-			values += fmt.Sprintf("%s, ", b.bindParam(value))
-		}
-		values = fmt.Sprintf("(%s)", trim(values))
+		return insertRows([]map[string]interface{}{realData})
 
 	case []map[string]interface{}:
-		var columnNames []string
-		// 先取得欄位的名稱，這樣才能照順序遍歷整個 `map`。
-		for name := range realData[0] {
-			columnNames = append(columnNames, name)
-			// 先建置欄位名稱的 SQL 指令片段。
-			columns += fmt.Sprintf("%s, ", name)
+		if len(realData) == 0 {
+			err = ErrIncorrectDataType
+			return
 		}
+		// 先取得欄位的名稱，這樣才能照順序遍歷整個 `map`。
+		columns = sortedKeys(realData[0])
 		for _, single := range realData {
-			var currentValues string
-			for _, name := range columnNames {
-				currentValues += fmt.Sprintf("%s, ", b.bindParam(single[name]))
+			row := make([]interface{}, len(columns))
+			for k, name := range columns {
+				row[k] = single[name]
 			}
-			values += fmt.Sprintf("(%s), ", trim(currentValues))
+			rows = append(rows, row)
 		}
-		values = trim(values)
+		return
 	}
-	columns = trim(columns)
-	query = fmt.Sprintf("%s %sINTO %s (%s) VALUES %s ", operator, beforeOptions, b.tableName[0], columns, values)
+	structs, ok := structValues(data)
+	if !ok || len(structs) == 0 {
+		err = ErrIncorrectDataType
+		return
+	}
+	columns, rows = structInsertRows(structs)
 	return
 }
 
@@ -622,115 +803,100 @@ func (b *Builder) runQuery() (rows *sql.Rows, err error) {
 	b.LastQuery = b.query
 	b.LastParams = b.params
 
-	// 如果有啟用追蹤模式的話，開始計算執行時間。
-	var start time.Time
-	if b.tracing {
-		start = time.Now()
-	}
-
 	// 如果這個建置建構體是可執行的話，就執行 SQL 指令。
 	if b.executable {
-		var stmt *sql.Stmt
-		var count int
-		var tx *sql.Tx
-
-		// 如果指令選項中有 `SQL_CALC_FOUND_ROWS` 的話就開始一段交易，
-		// 因為這個指令僅能用於同個連線中。
-		for _, v := range b.queryOptions {
-			if v == "SQL_CALC_FOUND_ROWS" {
-				// 開始一個交易。
-				tx, err = b.db.begin()
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				// 準備執行指令。
-				stmt, err = tx.Prepare(b.query)
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				// 傳入參數並且執行指令。
-				rows, err = stmt.Query(b.params...)
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				// 將取得到的結果映射置目的地指標。
-				// 這同時會關閉 `rows` 所以就不會觸發 `busy buffer` 錯誤。
-				count, err = load(rows, b.destination)
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				// 保存結果行數。
-				b.count = count
-
-				// 選擇 `FOUND_ROWS` 來取得總計的行數。
-				rows, err = tx.Query("SELECT FOUND_ROWS()")
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				// 掃描資料來取得總計的行數。
-				for rows.Next() {
-					var totalCount int
-					rows.Scan(&totalCount)
-					if rows.Err() != nil {
-						err = rows.Err()
-						b.saveTrace(err, b.query, start)
-						b.cleanAfter()
-						return
-					}
-					b.TotalCount = totalCount
-				}
-				// 關閉、結束整個指令環境。
-				err = stmt.Close()
-				if err != nil {
-					b.saveTrace(err, b.query, start)
-					b.cleanAfter()
-					return
-				}
-				b.saveTrace(err, b.query, start)
-				b.cleanAfter()
-				return
-			}
-		}
+		// 透過 `RawQuery` 執行的寫入指令同樣也會讓工作階段黏著主要資料庫。
+		defer b.session.wrote(b.query)
+		err = b.retry(isRead(b.query), func() (err error) {
+			rows, err = b.queryOnce()
+			return
+		})
+	} else {
+		b.saveTrace(nil, b.query, time.Now(), 1)
+	}
+	b.cleanAfter()
+	return
+}
 
-		// 如果沒有設置 `SQL_CALC_FOUND_ROWS` 的話就使用正常的連線池。
-		stmt, err = b.db.prepare(b.query)
+// queryOnce 會以 `Query` 的方式執行一次已建置的 SQL 指令，並將結果映射到目的地。
+func (b *Builder) queryOnce() (rows *sql.Rows, err error) {
+	var count int
+
+	// 如果指令選項中有 `SQL_CALC_FOUND_ROWS` 的話就開始一段交易，
+	// 因為這個指令僅能用於同個連線中。
+	for _, v := range b.queryOptions {
+		if v != "SQL_CALC_FOUND_ROWS" {
+			continue
+		}
+		// 開始一個交易。
+		var conn *connection
+		var done func()
+		conn, done, err = b.db.beginRead(b.context(), b.query, b.shouldUseMaster())
 		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
 			return
 		}
-		rows, err = stmt.Query(b.params...)
+		b.conn = conn
+		tx := conn.tx
+		defer done()
+		// 這個交易僅用於讀取，結束後回溯來歸還連線。
+		defer tx.Rollback()
+		// 準備執行指令。
+		var stmt *sql.Stmt
+		stmt, err = tx.PrepareContext(b.context(), b.query)
 		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
 			return
 		}
-		err = stmt.Close()
+		// 關閉、結束整個指令環境。
+		defer stmt.Close()
+		// 傳入參數並且執行指令。
+		rows, err = stmt.QueryContext(b.context(), b.params...)
 		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
 			return
 		}
+		// 將取得到的結果映射置目的地指標。
+		// 這同時會關閉 `rows` 所以就不會觸發 `busy buffer` 錯誤。
 		count, err = load(rows, b.destination)
 		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
 			return
 		}
+		// 保存結果行數。
 		b.count = count
+
+		// 選擇 `FOUND_ROWS` 來取得總計的行數。
+		rows, err = tx.QueryContext(b.context(), "SELECT FOUND_ROWS()")
+		if err != nil {
+			return
+		}
+		// 掃描資料來取得總計的行數。
+		for rows.Next() {
+			var totalCount int
+			rows.Scan(&totalCount)
+			if rows.Err() != nil {
+				err = rows.Err()
+				return
+			}
+			b.TotalCount = totalCount
+		}
+		return
 	}
-	b.saveTrace(err, b.query, start)
-	b.cleanAfter()
+
+	// 如果沒有設置 `SQL_CALC_FOUND_ROWS` 的話就使用正常的連線池。
+	stmt, err := b.db.prepare(b.context(), b.query, b.shouldUseMaster())
+	if err != nil {
+		return
+	}
+	b.conn = stmt.conn
+	// 直到結果被映射完畢之前，這個連線都被視為正在執行指令。
+	defer stmt.close()
+	rows, err = stmt.QueryContext(b.context(), b.params...)
+	if err != nil {
+		return
+	}
+	count, err = load(rows, b.destination)
+	if err != nil {
+		return
+	}
+	b.count = count
 	return
 }
 
@@ -741,48 +907,42 @@ func (b *Builder) executeQuery() (res sql.Result, err error) {
 	b.LastQuery = b.query
 	b.LastParams = b.params
 
-	// 如果有啟用追蹤模式的話，開始計算執行時間。
-	var start time.Time
-	if b.tracing {
-		start = time.Now()
-	}
-
 	// 如果這個建置建構體是可執行的話，就執行 SQL 指令。
 	if b.executable {
-		var stmt *sql.Stmt
-		var count int64
-		stmt, err = b.db.prepare(b.query)
-		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
-			return
-		}
-		res, err = stmt.Exec(b.params...)
-		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
-			return
-		}
-		b.LastResult = res
-		count, err = res.RowsAffected()
-		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
-			return
-		}
-		b.count = int(count)
-		err = stmt.Close()
-		if err != nil {
-			b.saveTrace(err, b.query, start)
-			b.cleanAfter()
+		defer b.session.wrote(b.query)
+		// 寫入指令重試可能會導致資料被重複寫入，所以只有明確啟用時才會重試。
+		err = b.retry(isRead(b.query) || b.retryPolicy.Writes, func() (err error) {
+			res, err = b.execOnce()
 			return
-		}
+		})
+	} else {
+		b.saveTrace(nil, b.query, time.Now(), 1)
 	}
-	b.saveTrace(err, b.query, start)
 	b.cleanAfter()
 	return
 }
 
+// execOnce 會透過 `Exec` 的方式執行一次已建置的 SQL 指令。
+func (b *Builder) execOnce() (res sql.Result, err error) {
+	stmt, err := b.db.prepare(b.context(), b.query, b.shouldUseMaster())
+	if err != nil {
+		return
+	}
+	b.conn = stmt.conn
+	defer stmt.close()
+	res, err = stmt.ExecContext(b.context(), b.params...)
+	if err != nil {
+		return
+	}
+	b.LastResult = res
+	count, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	b.count = int(count)
+	return
+}
+
 //=======================================================
 // 輸出函式
 //=======================================================
@@ -805,11 +965,36 @@ func (b *Builder) Get(columns ...string) (builder *Builder, err error) {
 	if err != nil {
 		return
 	}
+	var counter *Builder
+	if builder.countTotal {
+		counter = builder.clone()
+	}
 	_, err = builder.runQuery()
-	if err != nil {
+	if err != nil || counter == nil {
 		return
 	}
-	return
+	err = builder.loadTotalCount(counter)
+	return
+}
+
+// loadTotalCount 會以 `COUNT(*)` 包覆不含排序與筆數限制的同個查詢來取得總筆數，並將其保存到 `TotalCount`。
+// `counter` 是執行查詢之前的建置系統副本，這會被用在不支援 `SQL_CALC_FOUND_ROWS` 的方言上。
+func (b *Builder) loadTotalCount(counter *Builder) error {
+	counter.orders = []order{}
+	counter.limit = []int{}
+	counter.buildQuery()
+	query, params, useMaster := counter.query, counter.params, counter.useMaster
+	counter.cleanAfter()
+	counter.useMaster = useMaster
+	var total int
+	counter.destination = &total
+	result, err := counter.RawQuery(fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS total_count", query), params...)
+	b.Traces = append(b.Traces, result.Traces[len(counter.Traces):]...)
+	if err != nil {
+		return err
+	}
+	b.TotalCount = total
+	return nil
 }
 
 // GetValue 會取得單個欄位的資料（例如：字串、正整數）。
@@ -844,8 +1029,14 @@ func (b *Builder) Paginate(pageCount int, columns ...string) (builder *Builder,
 
 // WithTotalCount 會在 SQL 執行指令中安插 `SQL_CALC_FOUND_ROWS` 選項，
 // 如此一來就能夠在執行完 SQL 指令後取得查詢的總計行數。在不同情況下，這可能會拖低執行效能。
+// 不支援 `SQL_CALC_FOUND_ROWS` 的方言則會在查詢後另外以 `COUNT(*)` 查詢取得總計行數。
 func (b *Builder) WithTotalCount() (builder *Builder) {
-	builder = b.clone().SetQueryOption("SQL_CALC_FOUND_ROWS")
+	if option := b.dialect.CalcFoundRows(); option != "" {
+		builder = b.clone().SetQueryOption(option)
+		return
+	}
+	builder = b.clone()
+	builder.countTotal = true
 	return
 }
 
@@ -853,7 +1044,9 @@ func (b *Builder) WithTotalCount() (builder *Builder) {
 // 插入函式
 //=======================================================
 
-// Insert 會插入一筆新的資料。
+// Insert 會插入一筆新的資料，資料可以是 `map[string]interface{}` 或是帶有 `db` 標籤的結構體。
+// 傳入結構體指標時，最後插入的編號會被寫回標記為 `autoincrement` 的欄位。
+// 驅動程式不支援 `LastInsertId` 時，`LastInsertID` 會是零值而不會回傳錯誤。
 func (b *Builder) Insert(data interface{}) (builder *Builder, err error) {
 	builder = b.clone()
 	builder.query, err = builder.buildInsert("INSERT", data)
@@ -864,30 +1057,62 @@ func (b *Builder) Insert(data interface{}) (builder *Builder, err error) {
 	if err != nil || !builder.executable {
 		return
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
+	// 部分驅動程式（例如：PostgreSQL 的 `lib/pq`）不支援 `LastInsertId`，
+	// 這時資料已經被寫入，所以不會被視為錯誤，最後插入的編號則會是零值。
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
 		return
 	}
 	builder.LastInsertID = int(id)
+	assignInsertID(data, id)
 	return
 }
 
-// InsertMulti 會一次插入多筆資料。
+// InsertMulti 會一次插入多筆資料，資料可以是 `[]map[string]interface{}` 或是結構體切片。
+// 傳入結構體切片時，會以第一筆資料的編號依序寫回每筆資料中標記為 `autoincrement` 的欄位。
+// 資料會依照 `Chunk` 的設置與佔位符號數量上限分批插入，多個批次預設會在同一段交易中執行，
+// 所有批次異動的筆數總和能夠透過 `Count` 取得，每批的結果則會在 `Chunks` 中。
 func (b *Builder) InsertMulti(data interface{}) (builder *Builder, err error) {
 	builder = b.clone()
-	builder.query, err = builder.buildInsert("INSERT", data)
+	builder.cleanBefore()
+	_, rows, err := insertRows(data)
 	if err != nil {
 		return
 	}
-	res, err := builder.executeQuery()
-	if err != nil || !builder.executable {
-		return
+	ranges := builder.chunkRanges(rows)
+	run := func(tx *Builder) error {
+		builder.count = 0
+		builder.LastInsertID = 0
+		builder.Chunks = nil
+		for _, v := range ranges {
+			chunk, err := tx.Insert(sliceData(data, v[0], v[1]))
+			builder.LastQuery = chunk.LastQuery
+			builder.LastParams = chunk.LastParams
+			builder.LastResult = chunk.LastResult
+			builder.Traces = append(builder.Traces, chunk.Traces[len(tx.Traces):]...)
+			if err != nil {
+				return err
+			}
+			result := ChunkResult{Rows: v[1] - v[0], RowsAffected: chunk.count}
+			if chunk.LastInsertID != 0 {
+				result.FirstInsertID = chunk.LastInsertID
+				result.LastInsertID = chunk.LastInsertID + result.Rows - 1
+			}
+			if len(builder.Chunks) == 0 {
+				builder.LastInsertID = chunk.LastInsertID
+			}
+			builder.count += chunk.count
+			builder.Chunks = append(builder.Chunks, result)
+		}
+		return nil
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return
+	// 分批插入時每批都已經寫回了最後插入的編號，所以整段交易不會被重試，以免已經寫回編號的資料被重複插入。
+	if len(ranges) == 1 || builder.chunk.Independent || !builder.executable {
+		err = run(builder)
+	} else {
+		err = builder.runTransaction(run)
 	}
-	builder.LastInsertID = int(id)
+	builder.cleanAfter()
 	return
 }
 
@@ -919,10 +1144,13 @@ func (b *Builder) Replace(data interface{}) (builder *Builder, err error) {
 	return
 }
 
-// Update 會以指定的資料來更新相對應的資料列。
+// Update 會以指定的資料來更新相對應的資料列，資料可以是 `map[string]interface{}` 或是帶有 `db` 標籤的結構體。
 func (b *Builder) Update(data interface{}) (builder *Builder, err error) {
 	builder = b.clone()
 	builder.query, err = builder.buildUpdate(data)
+	if err != nil {
+		return
+	}
 	_, err = builder.executeQuery()
 	return
 }
@@ -937,6 +1165,14 @@ func (b *Builder) OnDuplicate(columns []string, lastInsertID ...string) (builder
 	return
 }
 
+// OnConflict 能夠指定用來判斷插入的資料是否重複的欄位，這會與 `OnDuplicate` 一同使用。
+// MySQL 會以唯一鍵自動判斷所以不需要指定，而 PostgreSQL 則必須指定。
+func (b *Builder) OnConflict(columns ...string) (builder *Builder) {
+	builder = b.clone()
+	builder.conflictColumns = columns
+	return
+}
+
 //=======================================================
 // 限制函式
 //=======================================================
@@ -1081,6 +1317,64 @@ func (b *Builder) JoinOrWhere(table interface{}, args ...interface{}) (builder *
 	return
 }
 
+//=======================================================
+// 組合函式
+//=======================================================
+
+// Union 會以 `UNION` 將子指令的結果與目前指令的結果組合在一起並移除重複的資料列，
+// 目前指令的 `OrderBy` 與 `Limit` 會套用在組合後的結果上。
+func (b *Builder) Union(subQueries ...*SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCompound("UNION", subQueries...)
+	return
+}
+
+// UnionAll 和 `Union` 相同，但會以 `UNION ALL` 保留重複的資料列。
+func (b *Builder) UnionAll(subQueries ...*SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCompound("UNION ALL", subQueries...)
+	return
+}
+
+// Intersect 會以 `INTERSECT` 僅保留同時存在於目前指令與子指令結果中的資料列，MySQL 需要 8.0.31 以上的版本。
+func (b *Builder) Intersect(subQueries ...*SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCompound("INTERSECT", subQueries...)
+	return
+}
+
+// Except 會以 `EXCEPT` 從目前指令的結果中移除存在於子指令結果中的資料列，MySQL 需要 8.0.31 以上的版本。
+func (b *Builder) Except(subQueries ...*SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCompound("EXCEPT", subQueries...)
+	return
+}
+
+//=======================================================
+// 公用資料表函式
+//=======================================================
+
+// With 會以 `WITH` 將子指令定義成指定名稱的公用資料表（CTE），這能夠在接下來的 `SELECT`、`UPDATE` 或 `DELETE` 中
+// 像一般的資料表格一樣被使用。多次呼叫會依照順序定義多個公用資料表，後面的公用資料表也能夠參照前面的。
+func (b *Builder) With(name string, subQuery *SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCommonTable(commonTable{name: name, query: subQuery})
+	return
+}
+
+// WithRecursive 會以 `WITH RECURSIVE` 定義一個遞迴的公用資料表，適合用來查詢組織圖或分類樹等階層資料。
+// `anchor` 是起始的資料列，`recursive` 則是以名稱參照這個公用資料表來取得下一層資料列的子指令，兩者會以 `UNION ALL` 組合。
+// `columns` 是公用資料表的欄位名稱，傳入 nil 則會使用 `anchor` 的欄位名稱。
+func (b *Builder) WithRecursive(name string, columns []string, anchor *SubQuery, recursive *SubQuery) (builder *Builder) {
+	builder = b.clone()
+	builder.saveCommonTable(commonTable{name: name, columns: columns, query: anchor, recursive: recursive})
+	return
+}
+
+//=======================================================
+// 子指令函式
+//=======================================================
+
 // SubQuery 能夠將目前的 SQL 指令轉換為子指令（Sub Query）來防止建置後直接被執行，這讓你可以將子指令傳入其他的條件式（例如：`WHERE`），
 // 若欲將子指令傳入插入（Join）條件中，必須在參數指定此子指令的別名。
 func (b *Builder) SubQuery(alias ...string) (subQuery *SubQuery) {
@@ -1088,6 +1382,8 @@ func (b *Builder) SubQuery(alias ...string) (subQuery *SubQuery) {
 		PageLimit: b.PageLimit,
 		builder: &Builder{
 			executable: false,
+			dialect:    b.dialect,
+			subQuery:   true,
 		},
 	}
 	if len(alias) > 0 {
@@ -1114,52 +1410,270 @@ func (b *Builder) Has() (builder *Builder, has bool, err error) {
 // 資料庫函式
 //=======================================================
 
-// Disconnect 會結束目前的資料庫連線。
+// Disconnect 會結束目前的資料庫連線，即使其中一個連線池關閉失敗也會繼續關閉其餘的連線池，
+// 並回傳所有發生的錯誤。
 func (b *Builder) Disconnect() (err error) {
 	err = b.db.disconnect()
 	return
 }
 
+// Shutdown 會停止接受新的指令與交易（這會回傳 `ErrShutdown`），並且等待正在執行的指令與尚未結束的交易完成，
+// 直到上下文結束為止，最後會關閉所有的連線池。回傳的錯誤會包含逾時與所有關閉連線池時發生的錯誤。
+// 已經開始的交易在等待期間仍然能夠執行指令並且提交或回溯。
+func (b *Builder) Shutdown(ctx context.Context) (err error) {
+	err = b.db.shutdown(ctx)
+	return
+}
+
 // Ping 會以 ping 來檢查資料庫連線。
 func (b *Builder) Ping() (err error) {
-	err = b.db.ping()
+	err = b.db.ping(b.context())
 	return
 }
 
-// Connect 會試圖在斷線之後重新連線至資料庫。
+// Connect 會試圖在斷線之後重新連線至資料庫，舊的連線池會在執行中的指令與尚未結束的交易結束後被關閉。
 func (b *Builder) Connect() (err error) {
 	err = b.db.connect()
 	return
 }
 
+// Reload 會以新的 DSN 重新建立主要資料庫與 Slave 資料庫的連線池（例如：更換密碼之後），
+// 所有新的連線池都必須能夠 ping 成功才會一起替換上去，否則會保留目前的連線池並回傳錯誤。
+// DSN 沒有變更的連線池會被沿用，所以這也能夠用來在執行期間新增或移除 Slave。
+// 被替換的舊連線池會在執行中的指令與尚未結束的交易結束後才被關閉。
+func (b *Builder) Reload(master string, slaves []string) (err error) {
+	err = b.db.reload(master, slaves)
+	return
+}
+
+// SetBalancer 會替換 Slave 資料庫的負載平衡器（預設為：`RoundRobinBalancer`），
+// 這會影響所有共用同個資料庫連線的建置系統。
+func (b *Builder) SetBalancer(balancer Balancer) {
+	b.db.setBalancer(balancer)
+}
+
+// RouteToMaster 會讓讀取指定資料表格的指令一律交由主要資料庫執行，即使有 Slave 資料庫也一樣，
+// 這適合用在不允許複寫延遲的資料表格上。這會影響所有共用同個資料庫連線的建置系統。
+func (b *Builder) RouteToMaster(tables ...string) {
+	b.db.addMasterTables(tables...)
+}
+
+// SetMetricsSink 會替換接收每次執行 SQL 指令結果的指標接收器，傳入 nil 表示停用，
+// 這會影響所有共用同個資料庫連線的建置系統。
+func (b *Builder) SetMetricsSink(sink MetricsSink) {
+	b.db.setMetricsSink(sink)
+}
+
+// StartHealthCheck 會在背景以指定的間隔定期 ping 所有的 Slave 資料庫，
+// 檢查失敗的 Slave 會被移出讀取輪詢，直到連續成功 `threshold` 次後才會重新加入。
+// 當所有的 Slave 都不健康時，讀取會改由主要資料庫處理。間隔小於等於零時會使用預設的 5 秒。
+func (b *Builder) StartHealthCheck(interval time.Duration, threshold int) {
+	b.db.startHealthCheck(interval, threshold)
+}
+
+// StopHealthCheck 會停止正在背景執行的健康檢查。
+func (b *Builder) StopHealthCheck() {
+	b.db.stopHealthCheck()
+}
+
+// StartLagMonitor 會在背景以指定的間隔定期讀取每個 Slave 的 `Seconds_Behind_Master`，
+// 複寫延遲超過 `threshold` 或無法得知延遲（例如複寫已經停止）的 Slave 會被移出讀取輪詢。
+// 量測到的延遲能夠透過 `Status` 取得。間隔小於等於零時會使用預設的 5 秒。
+func (b *Builder) StartLagMonitor(interval time.Duration, threshold time.Duration) {
+	b.db.startLagMonitor(interval, threshold)
+}
+
+// StopLagMonitor 會停止正在背景執行的複寫延遲監控。
+func (b *Builder) StopLagMonitor() {
+	b.db.stopLagMonitor()
+}
+
+// Status 會回傳所有資料庫連線的狀態資訊，主要資料庫的連線會在第一個。
+func (b *Builder) Status() []ConnectionStatus {
+	return b.db.status()
+}
+
+// StartFailover 會在背景定期 ping 主要資料庫，連續失敗 `Threshold` 次後會進入唯讀模式，
+// 寫入指令與交易會直接回傳 `ErrReadOnly`，而讀取指令會繼續由 Slave 處理。
+// 如果有指定備用資料庫，則會試著將其提升為主要資料庫並離開唯讀模式。
+func (b *Builder) StartFailover(config FailoverConfig) {
+	b.db.startFailover(config)
+}
+
+// StopFailover 會停止正在背景執行的故障轉移監控，並且離開唯讀模式。
+func (b *Builder) StopFailover() {
+	b.db.stopFailover()
+}
+
+// ReadOnly 會回傳資料庫目前是否因為主要資料庫無法連線而處於唯讀模式。
+func (b *Builder) ReadOnly() bool {
+	return b.db.isReadOnly()
+}
+
 //=======================================================
 // 交易函式
 //=======================================================
 
-// Begin 會開始一個新的交易。
+// Begin 會開始一個新的交易，如果有透過 `WithContext` 指定上下文，交易會在上下文被取消時自動回溯。
+// 在交易中呼叫 `Begin` 則會以 `SAVEPOINT` 開始一段巢狀交易，巢狀交易的 `Commit` 與 `Rollback`
+// 只會釋放或回溯到這層交易的儲存點，只有最外層的交易才會真正地提交或回溯。
 func (b *Builder) Begin() (builder *Builder, err error) {
+	return b.BeginTx(nil)
+}
+
+// BeginTx 和 `Begin` 相同，但能夠透過 `sql.TxOptions` 指定交易的隔離層級（例如：`sql.LevelSerializable`）
+// 或是開始一段唯讀交易。唯讀交易會在 Slave 上執行，這能夠讓報表等工作取得一致的快照而不會增加主要資料庫的負擔，
+// 透過 `UseMaster` 則能夠讓唯讀交易在主要資料庫上執行。巢狀交易因為無法變更隔離層級，所以會忽略這些選項。
+func (b *Builder) BeginTx(opts *sql.TxOptions) (builder *Builder, err error) {
 	builder = b.clone()
+	if builder.db.xa != nil {
+		err = ErrXATransaction
+		return
+	}
+	if builder.db.inTransaction() {
+		var db *DB
+		db, err = builder.db.beginSavepoint(builder.context())
+		if err != nil {
+			return
+		}
+		builder.db = db
+		return
+	}
+	var conn *connection
 	var tx *sql.Tx
-	tx, err = builder.db.begin()
+	var done func()
+	conn, tx, done, err = builder.db.begin(builder.context(), opts, builder.shouldUseMaster())
 	if err != nil {
 		return
 	}
-	newDB := *builder.db
-	newMaster := *newDB.master
-	newDB.master = &newMaster
-	newDB.master.tx = tx
-	builder.db = &newDB
+	builder.db = builder.db.withTransaction(conn, tx, done)
 	return
 }
 
 // Rollback 能夠回溯到交易剛開始的時候，並且在不保存資料變動的情況下結束交易。
 func (b *Builder) Rollback() error {
-	return b.db.rollback()
+	return b.db.rollback(b.context())
 }
 
 // Commit 會讓交易中所產生的資料異動成為永久紀錄並保存於資料庫中且結束交易。
 func (b *Builder) Commit() error {
-	return b.db.commit()
+	return b.db.commit(b.context())
+}
+
+// OnCommit 會加入一個在交易成功提交後才執行的函式（例如：發送事件、清除快取），多個函式會依照加入的順序執行。
+// 提交失敗或交易被回溯時這個函式都不會被執行。巢狀交易中加入的函式則會等到最外層的交易提交後才執行。
+// 不在交易中時會回傳 `ErrUnbegunTransaction`。
+func (b *Builder) OnCommit(fn func()) error {
+	return b.db.addHook(true, fn)
+}
+
+// OnRollback 會加入一個在交易成功回溯後才執行的函式，多個函式會依照加入的順序執行。
+// 巢狀交易中加入的函式會在回溯到這層交易的儲存點時執行，如果巢狀交易已經提交，則會等到外層交易回溯時才執行。
+// 不在交易中時會回傳 `ErrUnbegunTransaction`。
+func (b *Builder) OnRollback(fn func()) error {
+	return b.db.addHook(false, fn)
+}
+
+// Transaction 會開始一段新的交易並執行傳入的函式，函式中必須使用傳入的 `tx` 執行指令。
+// 函式回傳 nil 時會提交交易，回傳錯誤時會回溯交易並回傳該錯誤，發生 panic 時則會在回溯交易之後繼續 panic。
+// 有透過 `SetRetryPolicy` 設置重試策略時，遇到死結或序列化失敗會依照策略重新開始交易並再次執行整個函式。
+// 在交易中呼叫時則會以巢狀交易執行，因為死結會讓整個外層交易失敗，所以巢狀交易不會被重試。
+func (b *Builder) Transaction(fn func(tx *Builder) error) (err error) {
+	attempts := 1
+	if b.retryPolicy.MaxAttempts > 1 && !b.db.inTransaction() {
+		attempts = b.retryPolicy.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		err = b.runTransaction(fn)
+		if err == nil || attempt >= attempts || !isSerializationFailure(err) {
+			return
+		}
+		select {
+		case <-time.After(b.retryPolicy.delay(attempt)):
+		case <-b.context().Done():
+			return
+		}
+	}
+}
+
+// runTransaction 會在一段新的交易中執行一次傳入的函式，並依照結果提交或回溯交易。
+func (b *Builder) runTransaction(fn func(tx *Builder) error) (err error) {
+	tx, err := b.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			err = joinErrors(err, rollbackErr)
+		}
+		return
+	}
+	return tx.Commit()
+}
+
+// XABegin 會以指定的交易編號在主要資料庫上開始一段 XA 分散式交易，這能夠讓橫跨多個資料庫的寫入一起提交或回溯。
+// XA 交易會固定使用單個連線直到提交或回溯為止，所以必須使用回傳的建置系統執行指令。
+// 常見的流程是在每個資料庫上依序呼叫 `XABegin`、執行指令、`XAPrepare`，全部準備成功後再各自呼叫 `XACommit`。
+func (b *Builder) XABegin(xid string) (builder *Builder, err error) {
+	builder = b.clone()
+	var db *DB
+	db, err = builder.db.beginXA(builder.context(), xid)
+	if err != nil {
+		return
+	}
+	builder.db = db
+	return
+}
+
+// XAEnd 會以 `XA END` 結束在這段 XA 交易中執行指令，`XAPrepare`、`XACommit` 與 `XARollback` 也會在需要時自動呼叫它。
+func (b *Builder) XAEnd() error {
+	return b.db.endXA(b.context())
+}
+
+// XAPrepare 會以 `XA PREPARE` 準備提交這段 XA 交易，準備好的交易即使在連線中斷後也能夠被提交或回溯。
+func (b *Builder) XAPrepare() error {
+	if b.db.xa == nil {
+		return ErrUnbegunTransaction
+	}
+	return b.db.prepareXA(b.context())
+}
+
+// XACommit 會以 `XA COMMIT` 提交這段 XA 交易並歸還所固定的連線，沒有經過 `XAPrepare` 的交易則會以 `ONE PHASE` 直接提交。
+func (b *Builder) XACommit() error {
+	if b.db.xa == nil {
+		return ErrUnbegunTransaction
+	}
+	return b.db.finishXA(b.context(), true)
+}
+
+// XARollback 會以 `XA ROLLBACK` 回溯這段 XA 交易並歸還所固定的連線。
+func (b *Builder) XARollback() error {
+	if b.db.xa == nil {
+		return ErrUnbegunTransaction
+	}
+	return b.db.finishXA(b.context(), false)
+}
+
+// XARecover 會透過 `XA RECOVER` 列出主要資料庫上所有已經準備好但尚未提交或回溯的 XA 交易編號，
+// 這通常會在應用程式崩潰後重新啟動時，搭配 `XACommitPrepared` 或 `XARollbackPrepared` 處理遺留的交易。
+func (b *Builder) XARecover() ([]string, error) {
+	return b.db.recoverXA(b.context())
+}
+
+// XACommitPrepared 會提交一段已經準備好的 XA 交易，這不需要是開始該交易的連線。
+func (b *Builder) XACommitPrepared(xid string) error {
+	return b.db.resolveXA(b.context(), xid, true)
+}
+
+// XARollbackPrepared 會回溯一段已經準備好的 XA 交易，這不需要是開始該交易的連線。
+func (b *Builder) XARollbackPrepared(xid string) error {
+	return b.db.resolveXA(b.context(), xid, false)
 }
 
 //=======================================================
@@ -1212,20 +1726,23 @@ func (b *Builder) SetLockMethod(method string) (builder *Builder) {
 }
 
 // Lock 會以指定的上鎖方式來鎖定某個指定的資料表格，這能用以避免資料競爭問題。
+// 不支援 `LOCK TABLES` 的方言會回傳 `ErrUnsupportedLock`。
 func (b *Builder) Lock(tableNames ...string) (builder *Builder, err error) {
-	var tables string
-	for _, v := range tableNames {
-		tables += fmt.Sprintf("%s %s, ", v, b.lockMethod)
+	query := b.dialect.LockTables(tableNames, b.lockMethod)
+	if query == "" {
+		return b.clone(), ErrUnsupportedLock
 	}
-	tables = trim(tables)
-
-	builder, err = b.RawQuery(fmt.Sprintf("LOCK TABLES %s", tables))
+	builder, err = b.RawQuery(query)
 	return
 }
 
-// Unlock 能解鎖已鎖上的資料表格。
+// Unlock 能解鎖已鎖上的資料表格，不支援 `LOCK TABLES` 的方言會回傳 `ErrUnsupportedLock`。
 func (b *Builder) Unlock(tableNames ...string) (builder *Builder, err error) {
-	builder, err = b.RawQuery("UNLOCK TABLES")
+	query := b.dialect.UnlockTables()
+	if query == "" {
+		return b.clone(), ErrUnsupportedLock
+	}
+	builder, err = b.RawQuery(query)
 	return
 }
 
@@ -1236,6 +1753,48 @@ func (b *Builder) SetQueryOption(options ...string) (builder *Builder) {
 	return
 }
 
+// Chunk 會讓下一個 `InsertMulti` 依照指定的設置分批插入資料，這能夠避免單個指令超過資料庫的佔位符號數量上限或是 `max_allowed_packet`。
+func (b *Builder) Chunk(options ChunkOptions) (builder *Builder) {
+	builder = b.clone()
+	builder.chunk = options
+	return
+}
+
+// UseMaster 會讓下一個 SQL 指令強制在主要資料庫上執行，即使它是個讀取指令。
+func (b *Builder) UseMaster() (builder *Builder) {
+	builder = b.clone()
+	builder.useMaster = true
+	return
+}
+
+// StickyMaster 會開始一段讀寫一致的工作階段，透過回傳的建置系統（與其衍生的建置系統）執行任何寫入指令後，
+// 接下來 `window` 時間內的讀取都會被導向主要資料庫，以避免讀取到尚未複寫至 Slave 的舊資料。
+func (b *Builder) StickyMaster(window time.Duration) (builder *Builder) {
+	builder = b.clone()
+	builder.session = newSession(window)
+	return
+}
+
+// shouldUseMaster 會回傳下一個 SQL 指令是否應該強制在主要資料庫上執行。
+func (b *Builder) shouldUseMaster() bool {
+	return b.useMaster || b.session.sticky()
+}
+
+// SetRetryPolicy 會設置遇到暫時性錯誤（例如：死結、鎖定逾時、連線中斷）時的重試策略，
+// 讀取指令會依照策略自動重試，而寫入指令只有在 `Writes` 為 `true` 時才會重試。交易中的指令永遠不會被重試。
+func (b *Builder) SetRetryPolicy(policy RetryPolicy) (builder *Builder) {
+	builder = b.clone()
+	builder.retryPolicy = policy
+	return
+}
+
+// WithContext 會指定執行 SQL 指令時所使用的上下文，這能夠在上下文被取消或逾時的時候中止正在執行的指令。
+func (b *Builder) WithContext(ctx context.Context) (builder *Builder) {
+	builder = b.clone()
+	builder.ctx = ctx
+	return
+}
+
 // SetTrace 會決定蹤跡模式的開關，當設置為 `true` 時會稍微地拖慢效能，
 // 但你就能夠從 `Trace` 屬性中取得 SQL 執行後的堆疊與路徑結果。
 func (b *Builder) SetTrace(status bool) (builder *Builder) {
@@ -1274,13 +1833,23 @@ func (b *Builder) Params() (params []interface{}) {
 // Migration 會返回一個新的資料表格遷移建構體。
 // 主要是基於現有的資料庫連線來提供資料表格與欄位的的操作功能。
 func (b *Builder) Migration() *Migration {
-	return newMigration(b.db)
+	return newMigration(b.db).WithContext(b.context())
 }
 
 //=======================================================
 // 輔助函式
 //=======================================================
 
+// sortedKeys 會回傳依照名稱排序的 `map` 鍵名。
+func sortedKeys(data map[string]interface{}) (keys []string) {
+	keys = make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
 // trim 會清理接收到的字串，移除最後無謂的逗點與空白。
 func trim(input string) (result string) {
 	if len(input) == 0 {
@@ -1,8 +1,11 @@
 package reiner
 
 import (
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -119,6 +122,130 @@ func TestLimitUpdate(t *testing.T) {
 	assertEqual(assert, "UPDATE Users SET Password = ?, Username = ? LIMIT 10", builder.Query())
 }
 
+func TestColumnOrder(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{
+		"Username": "YamiOdymel",
+		"Age":      18,
+		"Password": "test",
+		"Email":    "yami@example.com",
+	}
+	for i := 0; i < 10; i++ {
+		builder, _ = builder.Table("Users").OnDuplicate([]string{"Password", "Age"}).Insert(data)
+		assert.Equal("INSERT INTO Users (Age, Email, Password, Username) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE Password = VALUES(Password), Age = VALUES(Age)", builder.Query())
+		assert.Equal([]interface{}{18, "yami@example.com", "test", "YamiOdymel"}, builder.Params())
+
+		builder, _ = builder.Table("Users").InsertMulti([]map[string]interface{}{data, data})
+		assert.Equal("INSERT INTO Users (Age, Email, Password, Username) VALUES (?, ?, ?, ?), (?, ?, ?, ?)", builder.Query())
+
+		builder, _ = builder.Table("Users").Where("ID", 1).Update(data)
+		assert.Equal("UPDATE Users SET Age = ?, Email = ?, Password = ?, Username = ? WHERE ID = ?", builder.Query())
+		assert.Equal([]interface{}{18, "yami@example.com", "test", "YamiOdymel", 1}, builder.Params())
+	}
+}
+
+type structUser struct {
+	ID        int    `db:"ID,autoincrement"`
+	Username  string `db:"Username"`
+	Nickname  string `db:"Nickname,omitempty"`
+	CreatedAt string `db:"CreatedAt,readonly"`
+	Secret    string `db:"-"`
+}
+
+func TestInsertStruct(t *testing.T) {
+	assert := assert.New(t)
+	builder, _ = builder.Table("Users").Insert(&structUser{Username: "YamiOdymel", CreatedAt: "now", Secret: "secret"})
+	assert.Equal("INSERT INTO Users (Username) VALUES (?)", builder.Query())
+	assert.Equal([]interface{}{"YamiOdymel"}, builder.Params())
+
+	builder, _ = builder.Table("Users").Replace(structUser{ID: 1, Username: "YamiOdymel", Nickname: "Yami"})
+	assert.Equal("REPLACE INTO Users (ID, Username, Nickname) VALUES (?, ?, ?)", builder.Query())
+	assert.Equal([]interface{}{1, "YamiOdymel", "Yami"}, builder.Params())
+
+	_, err := builder.Table("Users").Insert("YamiOdymel")
+	assert.Equal(ErrIncorrectDataType, err)
+}
+
+func TestInsertMultiStruct(t *testing.T) {
+	assert := assert.New(t)
+	builder, _ = builder.Table("Users").InsertMulti([]structUser{
+		{Username: "YamiOdymel"},
+		{Username: "Karisu", Nickname: "Kari"},
+	})
+	assert.Equal("INSERT INTO Users (Username, Nickname) VALUES (?, DEFAULT), (?, ?)", builder.Query())
+	assert.Equal([]interface{}{"YamiOdymel", "Karisu", "Kari"}, builder.Params())
+}
+
+func TestUpdateStruct(t *testing.T) {
+	assert := assert.New(t)
+	builder, _ = builder.Table("Users").Where("ID", 1).Update(structUser{ID: 1, Username: "Karisu", CreatedAt: "now"})
+	assert.Equal("UPDATE Users SET Username = ? WHERE ID = ?", builder.Query())
+	assert.Equal([]interface{}{"Karisu", 1}, builder.Params())
+
+	_, err := builder.Table("Users").Update([]structUser{{Username: "Karisu"}})
+	assert.Equal(ErrIncorrectDataType, err)
+}
+
+func TestInsertStructID(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master"})
+	assert.NoError(err)
+
+	u := &structUser{Username: "YamiOdymel"}
+	_, err = b.Table("Users").Insert(u)
+	assert.NoError(err)
+	assert.Equal(10, u.ID)
+
+	users := []*structUser{{Username: "YamiOdymel"}, {Username: "Karisu"}}
+	_, err = b.Table("Users").InsertMulti(users)
+	assert.NoError(err)
+	assert.Equal(10, users[0].ID)
+	assert.Equal(11, users[1].ID)
+
+	// 已經指定編號的資料不會被覆寫。
+	users = []*structUser{{ID: 3, Username: "YamiOdymel"}, {Username: "Karisu"}}
+	_, err = b.Table("Users").InsertMulti(users)
+	assert.NoError(err)
+	assert.Equal(3, users[0].ID)
+	assert.Equal(0, users[1].ID)
+}
+
+func TestInsertWithoutInsertID(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master-noid"})
+	assert.NoError(err)
+
+	u := &structUser{Username: "YamiOdymel"}
+	res, err := b.Table("Users").Insert(u)
+	assert.NoError(err)
+	assert.Equal(0, res.LastInsertID)
+	assert.Equal(1, res.Count())
+	assert.Equal(0, u.ID)
+
+	// 分批插入也不會因為無法取得編號而回溯整段交易。
+	commits := atomic.LoadInt64(&txCommits)
+	res, err = b.Table("Users").Chunk(ChunkOptions{Rows: 1}).InsertMulti([]map[string]interface{}{{"Username": "YamiOdymel"}, {"Username": "Karisu"}})
+	assert.NoError(err)
+	assert.Equal(commits+1, atomic.LoadInt64(&txCommits))
+	assert.Equal(2, res.Count())
+	assert.Equal([]ChunkResult{{Rows: 1, RowsAffected: 1}, {Rows: 1, RowsAffected: 1}}, res.Chunks)
+}
+
+type embeddedUser struct {
+	structUser
+	Age int `db:"Age,omitempty"`
+}
+
+func TestStructMap(t *testing.T) {
+	assert := assert.New(t)
+	m := structMap(reflect.TypeOf(embeddedUser{}))
+	assert.Equal([]int{0, 0}, m["ID"])
+	assert.Equal([]int{0, 2}, m["Nickname"])
+	assert.Equal([]int{1}, m["Age"])
+	_, ok := m["Secret"]
+	assert.False(ok)
+}
+
 func TestGet(t *testing.T) {
 	assert := assert.New(t)
 	builder, _ = builder.Table("Users").Get()
@@ -446,3 +573,26 @@ func TestHas(t *testing.T) {
 	builder, _, _ = builder.Table("Users").Where("Username", "yamiodymel").Where("Password", "123456").Has()
 	assertEqual(assert, "SELECT * FROM Users WHERE Username = ? AND Password = ? LIMIT 1", builder.Query())
 }
+
+func TestUseMaster(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(builder.UseMaster().shouldUseMaster())
+	b, _ := builder.UseMaster().Table("Users").Get()
+	assert.False(b.shouldUseMaster())
+}
+
+func TestStickyMaster(t *testing.T) {
+	assert := assert.New(t)
+	b := builder.StickyMaster(time.Hour)
+	assert.False(b.Table("Users").shouldUseMaster())
+
+	b.session.wrote("SELECT * FROM Users")
+	assert.False(b.shouldUseMaster())
+	b.session.wrote("INSERT INTO Users (Username) VALUES (?)")
+	assert.True(b.Table("Users").shouldUseMaster())
+	assert.False(builder.shouldUseMaster())
+
+	b = builder.StickyMaster(0)
+	b.session.wrote("DELETE FROM Users")
+	assert.False(b.shouldUseMaster())
+}
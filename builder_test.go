@@ -1,6 +1,7 @@
 package reiner
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -759,3 +760,19 @@ func TestRealNull(t *testing.T) {
 	})
 	assert.NoError(err)
 }
+
+func TestRealContext(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := rb.WithContext(ctx).Table("Users").Get()
+	assert.Equal(context.Canceled, err)
+
+	_, err = rb.WithContext(ctx).Begin()
+	assert.Equal(context.Canceled, err)
+
+	b, err := rb.WithContext(context.Background()).Table("Users").Where("Username", "NotInTransaction").Get()
+	assert.NoError(err)
+	assert.Equal(1, b.Count())
+}
@@ -0,0 +1,119 @@
+package reiner
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// maxPlaceholders 是 MySQL 與 PostgreSQL 單個指令中能夠使用的佔位符號數量上限。
+const maxPlaceholders = 65535
+
+// ChunkOptions 是 `InsertMulti` 分批插入的設置。即使沒有設置，超過佔位符號數量上限（`65535`）的資料也會自動被分批插入。
+type ChunkOptions struct {
+	// Rows 是每批最多插入幾筆資料，零值表示不限制。
+	Rows int
+	// Bytes 是每批指令估計的最大位元組數，能夠用來避免超過 MySQL 的 `max_allowed_packet`，零值表示不限制。
+	// 單筆資料就超過這個大小時，該筆資料會被單獨插入。
+	Bytes int
+	// Independent 表示每批資料會以各自獨立的指令執行，而不是在同一段交易中執行，發生錯誤時已經執行的批次不會被回溯。
+	Independent bool
+}
+
+// ChunkResult 是 `InsertMulti` 單批插入的結果。
+type ChunkResult struct {
+	// Rows 是這批插入的資料筆數。
+	Rows int
+	// RowsAffected 是這批指令所異動的資料筆數。
+	RowsAffected int
+	// FirstInsertID 與 LastInsertID 是這批資料的自動遞增編號範圍，這會依照 MySQL 的行為以第一筆資料的編號依序遞增計算，
+	// 所以在使用 `OnDuplicate` 時可能不正確。
+	FirstInsertID int
+	LastInsertID  int
+}
+
+// chunkRanges 會依照分批設置將資料列切分成數個 `[開始, 結束)` 的範圍，每批的佔位符號數量都不會超過上限。
+// 方言不支援 `DEFAULT` 時，使用預設值的欄位不同的相鄰資料列也會被分開，讓每批都能夠直接略過這些欄位。
+func (b *Builder) chunkRanges(rows [][]interface{}) (ranges [][2]int) {
+	var start, placeholders, size int
+	splitDefaults := b.dialect.DefaultValue() == ""
+	for i, row := range rows {
+		rowPlaceholders, rowBytes := rowSize(row)
+		full := placeholders+rowPlaceholders > maxPlaceholders ||
+			(b.chunk.Rows > 0 && i-start >= b.chunk.Rows) ||
+			(b.chunk.Bytes > 0 && size+rowBytes > b.chunk.Bytes) ||
+			(splitDefaults && i > start && defaultColumns(row) != defaultColumns(rows[i-1]))
+		if full && i > start {
+			ranges = append(ranges, [2]int{start, i})
+			start, placeholders, size = i, 0, 0
+		}
+		placeholders += rowPlaceholders
+		size += rowBytes
+	}
+	return append(ranges, [2]int{start, len(rows)})
+}
+
+// defaultColumns 會回傳單列資料中以預設值插入（不含自動遞增）的欄位位置，用來比較相鄰的資料列是否能夠在同一批中插入。
+func defaultColumns(row []interface{}) (columns string) {
+	for k, v := range row {
+		if d, ok := v.(defaultValue); ok && !d.autoIncrement {
+			columns += fmt.Sprintf("%d,", k)
+		}
+	}
+	return
+}
+
+// rowSize 會回傳單列資料所使用的佔位符號數量，以及這列資料在指令中估計的位元組數。
+func rowSize(row []interface{}) (placeholders, size int) {
+	// 每列資料都會有括號與逗點。
+	size = 4
+	for _, v := range row {
+		switch d := v.(type) {
+		case nil, defaultValue:
+			size += len("DEFAULT, ")
+			continue
+		case Function:
+			placeholders += len(d.values)
+			size += len(d.query) + 2
+			for _, value := range d.values {
+				size += valueSize(value)
+			}
+			continue
+		case *SubQuery:
+			placeholders += len(d.builder.Params())
+			size += len(d.builder.query) + 4
+			for _, value := range d.builder.Params() {
+				size += valueSize(value)
+			}
+			continue
+		}
+		placeholders++
+		size += len("?, ") + valueSize(v)
+	}
+	return
+}
+
+// valueSize 會回傳單個參數傳送到資料庫時估計的位元組數。
+func valueSize(v interface{}) int {
+	switch d := v.(type) {
+	case string:
+		return len(d)
+	case []byte:
+		return len(d)
+	case nil:
+		return 0
+	}
+	return 8
+}
+
+// sliceData 會回傳插入資料中 `[from, to)` 範圍的資料，切片中的元素仍然會指向原本的資料，
+// 這樣最後插入的編號才能夠被寫回原本的結構體中。
+func sliceData(data interface{}, from, to int) interface{} {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice || (from == 0 && to == v.Len()) {
+		return data
+	}
+	return v.Slice(from, to).Interface()
+}
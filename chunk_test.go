@@ -0,0 +1,74 @@
+package reiner
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkRanges(t *testing.T) {
+	assert := assert.New(t)
+	rows := make([][]interface{}, 5)
+	for i := range rows {
+		rows[i] = []interface{}{"YamiOdymel", 18}
+	}
+	b := &Builder{dialect: MySQL{}}
+	assert.Equal([][2]int{{0, 5}}, b.chunkRanges(rows))
+	b.chunk = ChunkOptions{Rows: 2}
+	assert.Equal([][2]int{{0, 2}, {2, 4}, {4, 5}}, b.chunkRanges(rows))
+
+	// 超過大小的單筆資料會被單獨插入。
+	rows[1] = []interface{}{strings.Repeat("a", 100), 18}
+	b.chunk = ChunkOptions{Bytes: 60}
+	assert.Equal([][2]int{{0, 1}, {1, 2}, {2, 4}, {4, 5}}, b.chunkRanges(rows))
+
+	// 即使沒有設置，也不會超過佔位符號數量上限。
+	rows = make([][]interface{}, 40000)
+	for i := range rows {
+		rows[i] = []interface{}{"YamiOdymel", Function{query: "SHA1(?)", values: []interface{}{"test"}}, nil}
+	}
+	b.chunk = ChunkOptions{}
+	assert.Equal([][2]int{{0, 32767}, {32767, 40000}}, b.chunkRanges(rows))
+}
+
+func TestChunkInsertMulti(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+	users := make([]*structUser, 5)
+	for i := range users {
+		users[i] = &structUser{Username: "YamiOdymel"}
+	}
+
+	commits := atomic.LoadInt64(&txCommits)
+	res, err := b.Table("Users").Chunk(ChunkOptions{Rows: 2}).InsertMulti(users)
+	assert.NoError(err)
+	assert.Equal(commits+1, atomic.LoadInt64(&txCommits))
+	assert.Equal(3, res.Count())
+	assert.Equal(10, res.LastInsertID)
+	assert.Equal([]ChunkResult{
+		{Rows: 2, RowsAffected: 1, FirstInsertID: 10, LastInsertID: 11},
+		{Rows: 2, RowsAffected: 1, FirstInsertID: 10, LastInsertID: 11},
+		{Rows: 1, RowsAffected: 1, FirstInsertID: 10, LastInsertID: 10},
+	}, res.Chunks)
+	assert.Equal("INSERT INTO Users (Username) VALUES (?)", res.Query())
+	assert.Equal(11, users[3].ID)
+	assert.Equal(10, users[4].ID)
+
+	// 各自獨立的批次不會開始交易。
+	res, err = b.Table("Users").Chunk(ChunkOptions{Rows: 2, Independent: true}).InsertMulti([]map[string]interface{}{
+		{"Username": "YamiOdymel"},
+		{"Username": "Karisu"},
+		{"Username": "Mashiro"},
+	})
+	assert.NoError(err)
+	assert.Equal(commits+1, atomic.LoadInt64(&txCommits))
+	assert.Len(res.Chunks, 2)
+	assert.Equal(2, res.Count())
+
+	// 分批設置只會套用到下一個指令。
+	res, err = res.Table("Users").InsertMulti([]map[string]interface{}{{"Username": "YamiOdymel"}, {"Username": "Karisu"}})
+	assert.NoError(err)
+	assert.Len(res.Chunks, 1)
+}
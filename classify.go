@@ -0,0 +1,229 @@
+package reiner
+
+import (
+	"strings"
+	"unicode"
+)
+
+// queryInfo 是分析 SQL 指令後所得到的路由資訊。
+type queryInfo struct {
+	// read 表示這是否為能夠交由 Slave 執行的讀取指令，帶有鎖定的讀取指令不會被視為讀取指令。
+	read bool
+	// tables 是指令中透過 `FROM` 與 `JOIN` 所讀取的資料表格名稱。
+	tables []string
+}
+
+// sqlToken 是 SQL 指令中的單個字詞或符號，字串與註解不會成為字詞。
+type sqlToken struct {
+	text string
+	// depth 是這個字詞所在的括號深度。
+	depth int
+	// quoted 表示這是否為以引號包覆的識別符號。
+	quoted bool
+}
+
+// word 會回傳大寫的字詞，以引號包覆的識別符號則會回傳空字串，避免被當作關鍵字。
+func (t sqlToken) word() string {
+	if t.quoted {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// tableStopWords 是會在 `FROM` 之後結束資料表格清單的關鍵字，其他的字詞會被當作資料表格的別名。
+var tableStopWords = map[string]bool{
+	"WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true, "CROSS": true,
+	"NATURAL": true, "STRAIGHT_JOIN": true, "ON": true, "USING": true, "GROUP": true, "ORDER": true,
+	"LIMIT": true, "HAVING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "FOR": true,
+	"LOCK": true, "WINDOW": true, "INTO": true, "PARTITION": true, "FORCE": true, "IGNORE": true, "USE": true,
+}
+
+// isRead 會回傳 SQL 查詢指令是否為能夠交由 Slave 執行的讀取指令。
+func isRead(query string) bool {
+	return classify(query).read
+}
+
+// classify 會分析 SQL 指令的種類與其所讀取的資料表格。開頭的空白、註解與括號會被略過，
+// `WITH` 指令會依照主要的指令種類判斷，`SHOW`、`EXPLAIN` 與 `DESCRIBE` 會被視為讀取指令，
+// 而帶有 `FOR UPDATE`、`FOR SHARE` 或 `LOCK IN SHARE MODE` 的讀取指令則必須在主要資料庫上執行。
+func classify(query string) (info queryInfo) {
+	tokens := tokenize(query)
+	i := 0
+	for i < len(tokens) && tokens[i].text == "(" {
+		i++
+	}
+	if i == len(tokens) {
+		return
+	}
+
+	switch tokens[i].word() {
+	case "SELECT", "TABLE", "VALUES":
+		info.read = !isLocking(tokens) && !selectsInto(tokens)
+	case "WITH":
+		info.read = mainVerb(tokens[i+1:], tokens[i].depth) == "SELECT" && !isLocking(tokens) && !selectsInto(tokens)
+	case "SHOW":
+		info.read = true
+	// `EXPLAIN ANALYZE` 在某些資料庫中會真的執行被解釋的指令。
+	case "EXPLAIN", "DESCRIBE", "DESC":
+		info.read = !hasWord(tokens, "ANALYZE") || mainVerb(tokens[i+1:], tokens[i].depth) == "SELECT"
+	}
+	info.tables = readTables(tokens)
+	return
+}
+
+// tokenize 會將 SQL 指令拆分成字詞與符號，並且略過字串與註解。
+func tokenize(query string) (tokens []sqlToken) {
+	runes := []rune(query)
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+		// `-- 註解` 與 `# 註解`。
+		case r == '#' || (r == '-' && i+1 < len(runes) && runes[i+1] == '-'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		// `/* 註解 */`。
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		// 字串或以引號包覆的識別符號。
+		case r == '\'' || r == '"' || r == '`':
+			start := i + 1
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && r == '\'' {
+					i++
+					continue
+				}
+				if runes[i] == r {
+					break
+				}
+			}
+			if r != '\'' {
+				tokens = append(tokens, sqlToken{text: string(runes[start:i]), depth: depth, quoted: true})
+			}
+		case r == '(':
+			tokens = append(tokens, sqlToken{text: "(", depth: depth})
+			depth++
+		case r == ')':
+			depth--
+			tokens = append(tokens, sqlToken{text: ")", depth: depth})
+		case isWordRune(r):
+			start := i
+			for i+1 < len(runes) && isWordRune(runes[i+1]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start : i+1]), depth: depth})
+		default:
+			tokens = append(tokens, sqlToken{text: string(r), depth: depth})
+		}
+	}
+	return
+}
+
+// isWordRune 會回傳這個字元是否能夠作為字詞的一部分（例如：`Users`、`db.Users`、`@var`）。
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '.' || r == '@'
+}
+
+// mainVerb 會回傳 `WITH` 或 `EXPLAIN` 之後位於相同括號深度的主要指令種類。
+func mainVerb(tokens []sqlToken, depth int) string {
+	for _, v := range tokens {
+		if v.depth != depth {
+			continue
+		}
+		switch w := v.word(); w {
+		case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "TABLE", "VALUES":
+			return w
+		}
+	}
+	return ""
+}
+
+// hasWord 會回傳指令中是否有指定的關鍵字。
+func hasWord(tokens []sqlToken, word string) bool {
+	for _, v := range tokens {
+		if v.word() == word {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocking 會回傳指令中是否帶有 `FOR UPDATE`、`FOR SHARE`、`FOR NO KEY UPDATE`、`FOR KEY SHARE` 或 `LOCK IN SHARE MODE` 的鎖定。
+func isLocking(tokens []sqlToken) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		switch tokens[i].word() {
+		case "FOR":
+			switch tokens[i+1].word() {
+			case "UPDATE", "SHARE", "NO", "KEY":
+				return true
+			}
+		case "LOCK":
+			if tokens[i+1].word() == "IN" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectsInto 會回傳指令是否會將讀取的結果寫入到資料表格中（例如：PostgreSQL 的 `SELECT * INTO NewUsers FROM Users`），
+// 寫入到變數（`INTO @var`）或檔案（`INTO OUTFILE`）則不算在內。
+func selectsInto(tokens []sqlToken) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].word() != "INTO" {
+			continue
+		}
+		next := tokens[i+1]
+		if strings.HasPrefix(next.text, "@") || next.word() == "OUTFILE" || next.word() == "DUMPFILE" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// readTables 會回傳指令中透過 `FROM` 與 `JOIN` 讀取的資料表格名稱，這包括了以逗號分隔的多個資料表格與子指令中的資料表格。
+func readTables(tokens []sqlToken) (tables []string) {
+	for i, v := range tokens {
+		w := v.word()
+		if w != "FROM" && w != "JOIN" {
+			continue
+		}
+		depth := v.depth
+		for j := i + 1; j < len(tokens); j++ {
+			switch {
+			// 子指令中的資料表格會在之後遇到它的 `FROM` 時才取得，這裡僅需要跳過它。
+			case tokens[j].text == "(" && !tokens[j].quoted:
+				for j+1 < len(tokens) && tokens[j+1].depth > depth {
+					j++
+				}
+				j++
+			case tokens[j].quoted || isWordRune([]rune(tokens[j].text)[0]):
+				tables = append(tables, tokens[j].text)
+			}
+			// 略過別名直到下一個以逗號分隔的資料表格。
+			for j+1 < len(tokens) && tokens[j+1].depth == depth && isAlias(tokens[j+1]) {
+				j++
+			}
+			if w == "JOIN" || j+1 >= len(tokens) || tokens[j+1].text != "," {
+				break
+			}
+			j++
+		}
+	}
+	return
+}
+
+// isAlias 會回傳這個字詞是否可能為資料表格的別名（或 `AS`）。
+func isAlias(t sqlToken) bool {
+	if t.quoted {
+		return true
+	}
+	return isWordRune([]rune(t.text)[0]) && !tableStopWords[t.word()]
+}
@@ -0,0 +1,74 @@
+package reiner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyRead(t *testing.T) {
+	assert := assert.New(t)
+	reads := []string{
+		"SELECT * FROM Users",
+		"select * from Users",
+		"  \n\tSELECT * FROM Users",
+		"/* 註解 */ SELECT * FROM Users",
+		"-- 註解\nSELECT * FROM Users",
+		"(SELECT ID FROM Users) UNION (SELECT ID FROM Admins)",
+		"WITH Recent AS (SELECT * FROM Orders) SELECT * FROM Recent",
+		"WITH RECURSIVE Tree (ID) AS (SELECT 1 UNION ALL SELECT ID + 1 FROM Tree) SELECT * FROM Tree",
+		"SHOW TABLES",
+		"EXPLAIN SELECT * FROM Users",
+		"DESCRIBE Users",
+		"SELECT * FROM Users WHERE Username = 'FOR UPDATE'",
+		"SELECT @count := COUNT(*) FROM Users",
+		"SELECT * INTO @id FROM Users",
+	}
+	for _, v := range reads {
+		assert.True(isRead(v), v)
+	}
+	writes := []string{
+		"INSERT INTO Users (Username) VALUES (?)",
+		"UPDATE Users SET Username = ?",
+		"WITH Old AS (SELECT ID FROM Users) DELETE FROM Users WHERE ID IN (SELECT ID FROM Old)",
+		"SELECT * FROM Users FOR UPDATE",
+		"select * from Users for share",
+		"SELECT * FROM Users LOCK IN SHARE MODE",
+		"(SELECT * FROM Users WHERE ID = 1 FOR UPDATE)",
+		"SELECT * INTO NewUsers FROM Users",
+		"EXPLAIN ANALYZE DELETE FROM Users",
+		"",
+		"/* SELECT */",
+	}
+	for _, v := range writes {
+		assert.False(isRead(v), v)
+	}
+}
+
+func TestClassifyTables(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"Users"}, classify("SELECT * FROM Users WHERE ID = ?").tables)
+	assert.Equal([]string{"Users", "Products"}, classify("SELECT * FROM Users AS u, Products p WHERE u.ID = p.UserID").tables)
+	assert.Equal([]string{"Users", "Balances"}, classify("SELECT * FROM `Users` u LEFT JOIN Balances b ON u.ID = b.UserID").tables)
+	assert.Equal([]string{"Users", "Balances"}, classify("SELECT * FROM Users WHERE ID IN (SELECT UserID FROM Balances)").tables)
+	assert.Equal([]string{"Balances"}, classify("SELECT * FROM (SELECT * FROM Balances) AS b").tables)
+	assert.Equal([]string{"bank.Balances"}, classify("SELECT * FROM bank.Balances").tables)
+}
+
+func TestClassifyRouting(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+
+	assert.Equal(d.slaves[0], d.getDB("select * from Users", false))
+	assert.Equal(d.master, d.getDB("SELECT * FROM Users FOR UPDATE", false))
+
+	d.addMasterTables("balances")
+	assert.Equal(d.master, d.getDB("SELECT * FROM Balances", false))
+	assert.Equal(d.master, d.getDB("SELECT * FROM bank.Balances", false))
+	assert.Equal(d.master, d.getDB("SELECT * FROM Users JOIN Balances ON Users.ID = Balances.UserID", false))
+	assert.Equal(d.slaves[0], d.getDB("SELECT * FROM Users", false))
+
+	// 唯讀模式中仍然會從 Slave 讀取。
+	d.setReadOnly(true)
+	assert.Equal(d.slaves[0], d.getDB("SELECT * FROM Balances", false))
+}
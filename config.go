@@ -0,0 +1,47 @@
+package reiner
+
+import "time"
+
+// PoolConfig 是單個資料庫連線池的設置，零值表示使用 `database/sql` 的預設值。
+type PoolConfig struct {
+	// MaxOpenConns 是連線池最多能夠同時開啟的連線數量。
+	MaxOpenConns int
+	// MaxIdleConns 是連線池最多能夠保留的閒置連線數量，負數表示不保留任何閒置連線。
+	MaxIdleConns int
+	// ConnMaxLifetime 是單個連線能夠被重複使用的最長時間。
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime 是單個連線能夠閒置的最長時間。
+	ConnMaxIdleTime time.Duration
+}
+
+// Config 是建立資料庫連線時的設置，這能夠透過 `NewWithConfig` 使用。
+type Config struct {
+	// Master 是主要資料庫的 DSN（資料來源名稱），沒有指定時會變成 SQL 指令建置模式。
+	Master string
+	// Dialect 是資料庫的 SQL 方言，這同時也決定了開啟連線時所使用的驅動程式，未指定時會是 `MySQL`。
+	Dialect Dialect
+	// Slaves 是 Slave 資料庫的 DSN。
+	Slaves []string
+	// Weights 是以 DSN 為鍵的 Slave 權重，有指定時預設的負載平衡器會是 `WeightedBalancer`。
+	Weights map[string]int
+	// Balancer 是 Slave 資料庫的負載平衡器，未指定時會依照是否有權重來決定。
+	Balancer Balancer
+	// MasterTables 是不論何時都必須從主要資料庫讀取的資料表格（例如：餘額等不允許複寫延遲的資料）。
+	MasterTables []string
+	// MasterPool 是主要資料庫的連線池設置。
+	MasterPool PoolConfig
+	// SlavePool 是每個 Slave 資料庫的連線池設置。
+	SlavePool PoolConfig
+	// ConnectTimeout 是啟動時 ping 每個資料庫的最長等待時間，零值表示不限制。
+	ConnectTimeout time.Duration
+	// PingOnStartup 表示是否要在啟動時 ping 所有的資料庫來確保它們都能夠連線。
+	PingOnStartup bool
+	// StatementCacheSize 是每個連線池最多能夠快取的已準備指令數量，零值表示不快取，每次執行時都會重新準備指令。
+	StatementCacheSize int
+	// CircuitBreaker 是每個連線的斷路器設置，有指定時斷路中的 Slave 不會被用來讀取資料。
+	CircuitBreaker *CircuitBreakerConfig
+	// Metrics 是接收每次執行 SQL 指令結果的指標接收器，能夠使用內建的 `NewMetrics`。
+	Metrics MetricsSink
+	// Failover 是主要資料庫的故障轉移設置，有指定時會在建立後自動開始監控主要資料庫。
+	Failover *FailoverConfig
+}
@@ -0,0 +1,43 @@
+package reiner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigBuilderMode(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{})
+	assert.NoError(err)
+	b, err = b.Table("Users").Get()
+	assert.NoError(err)
+	assertEqual(assert, "SELECT * FROM Users", b.Query())
+}
+
+func TestConfigPool(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{
+		Master:     "root:root@tcp(127.0.0.1:1)/master",
+		Slaves:     []string{"root:root@tcp(127.0.0.1:1)/slave"},
+		Weights:    map[string]int{"root:root@tcp(127.0.0.1:1)/slave": 3},
+		MasterPool: PoolConfig{MaxOpenConns: 5},
+		SlavePool:  PoolConfig{MaxOpenConns: 10, ConnMaxLifetime: time.Hour},
+	})
+	assert.NoError(err)
+	assert.Equal(5, b.db.master.db.Stats().MaxOpenConnections)
+	assert.Equal(10, b.db.slaves[0].db.Stats().MaxOpenConnections)
+	assert.Equal(3, b.db.slaves[0].weight)
+	assert.IsType(&WeightedBalancer{}, b.db.balancer)
+}
+
+func TestConfigPingOnStartup(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewWithConfig(Config{
+		Master:         "root:root@tcp(127.0.0.1:1)/master",
+		ConnectTimeout: time.Second,
+		PingOnStartup:  true,
+	})
+	assert.Error(err)
+}
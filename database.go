@@ -1,146 +1,503 @@
 package reiner
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // connection 重現了一個資料庫的連線。
 type connection struct {
-	db             *sql.DB
-	tx             *sql.Tx
-	lastCheck      int
-	isHealth       bool
+	db *sql.DB
+	tx *sql.Tx
+	// conn 是 XA 交易所固定使用的單個連線，XA 交易中的所有指令都必須在同個連線上執行。
+	conn      *sql.Conn
+	lastCheck time.Time
+	isHealth  bool
+	// successCount 是連線在不健康之後所累積的連續健康檢查成功次數。
+	successCount int
+	// lag 是這個連線作為 Slave 時最後一次量測到的複寫延遲。
+	lag time.Duration
+	// lagging 表示這個 Slave 的複寫延遲是否超過限制或無法得知。
+	lagging        bool
 	dataSourceName string
+	// weight 是這個連線作為 Slave 時的負載平衡權重。
+	weight int
+	// inFlight 是這個連線目前正在執行中的指令數量，必須以 `sync/atomic` 存取。
+	inFlight *int64
+	// openTx 是這個連線上尚未結束的交易數量（包括 XA 交易），必須以 `sync/atomic` 存取。
+	openTx *int64
+	// stmts 是這個連線池的已準備指令快取，沒有啟用時會是 nil。
+	stmts *stmtCache
+	// breaker 是這個連線的斷路器，沒有啟用時會是 nil。
+	breaker *breaker
+	// lock 保護了健康狀態，因為健康檢查會在其他的 Goroutine 中更新這些資料。
+	lock *sync.RWMutex
 }
 
 // DB 是一個擁有許多連線的資料庫來源。
 type DB struct {
-	slaves         []*connection
-	master         *connection
-	hasSlave       bool
-	lastSlaveIndex int
+	slaves []*connection
+	master *connection
+	// dialect 是這個資料庫的 SQL 方言。
+	dialect Dialect
+	// balancer 會決定每次讀取時該使用哪一個 Slave。
+	balancer Balancer
+	// config 是建立這個資料庫時的設置，重新連線時會再次套用連線池的設置。
+	config Config
+	// healthChecker 是正在背景執行的健康檢查器，沒有啟用時會是 nil。
+	healthChecker *healthChecker
+	// lagMonitor 是正在背景執行的複寫延遲監控器，沒有啟用時會是 nil。
+	lagMonitor *lagMonitor
+	// failover 是正在背景執行的故障轉移監控器，沒有啟用時會是 nil。
+	failover *failover
+	// metrics 是接收指令執行結果的指標接收器，沒有設置時會是 nil。
+	metrics MetricsSink
+	// masterTables 是必須從主要資料庫讀取的資料表格，鍵為小寫的資料表格名稱。
+	masterTables map[string]bool
+	// work 追蹤了正在執行的指令與尚未結束的交易，讓 `Shutdown` 能夠等待它們完成。
+	work *workTracker
+	// endTx 會在交易中的副本結束交易時被呼叫，表示這段交易已經不再需要等待。
+	endTx func()
+	// hooks 是這層交易在提交或回溯後所要執行的函式，不在交易中時會是 nil。
+	hooks *txHooks
+	// xa 是這個副本所在的 XA 交易，不在 XA 交易中時會是 nil。
+	xa *xaBranch
+	// savepoint 是巢狀交易的層級，最外層的交易是 `0`，每層巢狀交易都會使用名為 `sp_N` 的儲存點。
+	savepoint int
+	// readOnly 表示主要資料庫目前無法連線，所有的寫入指令都會直接回傳 `ErrReadOnly`。
+	readOnly bool
+	// lock 保護了資料庫來源中會在執行期間被變更的設置。
+	lock *sync.RWMutex
+	// reloadLock 讓重新載入、重新連線與提升備用資料庫不會同時替換連線池，
+	// 這會在讀取目前的連線、開啟新的連線池到替換完成的期間一直被持有。
+	reloadLock *sync.Mutex
 }
 
-// openDatabase 會開啟一個新的資料庫連線。
-func openDatabase(dataSourceName string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dataSourceName)
+// newConnection 會基於一個已開啟的資料庫建立一個預設為健康的連線，
+// 並依照設置決定這個連線的權重與已準備指令快取的容量。
+func newConnection(db *sql.DB, dataSourceName string, config Config) *connection {
+	c := &connection{
+		db:             db,
+		isHealth:       true,
+		dataSourceName: dataSourceName,
+		weight:         1,
+		inFlight:       new(int64),
+		openTx:         new(int64),
+		stmts:          newStmtCache(config.StatementCacheSize),
+		breaker:        newBreaker(config.CircuitBreaker),
+		lock:           &sync.RWMutex{},
+	}
+	if weight, ok := config.Weights[dataSourceName]; ok {
+		c.weight = weight
+	}
+	return c
+}
+
+// acquire 會將這個連線的執行中指令數量加一，並且回傳連線本身以便串連使用。
+func (c *connection) acquire() *connection {
+	atomic.AddInt64(c.inFlight, 1)
+	return c
+}
+
+// executor 是能夠執行 SQL 指令的交易或單個連線。
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// pinned 會回傳這個連線目前所在的交易或 XA 交易所固定的連線，兩者都沒有時會回傳 nil。
+func (c *connection) pinned() executor {
+	if c.tx != nil {
+		return c.tx
+	}
+	if c.conn != nil {
+		return c.conn
+	}
+	return nil
+}
+
+// release 會在指令執行完畢後將這個連線的執行中指令數量減一。
+func (c *connection) release() {
+	atomic.AddInt64(c.inFlight, -1)
+}
+
+// pending 會回傳這個連線目前正在執行中的指令數量。
+func (c *connection) pending() int64 {
+	return atomic.LoadInt64(c.inFlight)
+}
+
+// trackTx 會將這個連線尚未結束的交易數量加一，並回傳一個在交易結束時呼叫的函式，
+// 這會將數量減一並且呼叫 `done`，重複呼叫該函式並不會有任何影響。
+func (c *connection) trackTx(done func()) func() {
+	atomic.AddInt64(c.openTx, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(c.openTx, -1)
+			done()
+		})
+	}
+}
+
+// openTransactions 會回傳這個連線上尚未結束的交易數量。
+func (c *connection) openTransactions() int64 {
+	return atomic.LoadInt64(c.openTx)
+}
+
+// openDatabase 會開啟一個新的資料庫連線並套用連線池的設置，
+// 如果有啟用 `PingOnStartup` 則會在 `ConnectTimeout` 內 ping 資料庫來確保能夠連線。
+func openDatabase(dataSourceName string, pool PoolConfig, config Config) (*sql.DB, error) {
+	db, err := sql.Open(config.Dialect.DriverName(), dataSourceName)
 	if err != nil {
 		return db, err
 	}
-	if err = db.Ping(); err != nil {
+	configurePool(db, pool)
+	if !config.PingOnStartup {
+		return db, nil
+	}
+	ctx := context.Background()
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+	if err = db.PingContext(ctx); err != nil {
 		return db, err
 	}
 	return db, nil
 }
 
+// configurePool 會將連線池的設置套用到資料庫上，零值的設置會保留 `database/sql` 的預設值。
+func configurePool(db *sql.DB, pool PoolConfig) {
+	if pool.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+}
+
 // newDatabase 會建立一個新的資料庫，當有主從來源時會替這個資料庫建立多個連線。
 // 如果僅有單個主要來源的話則會建立一個最主要的連線。
-func newDatabase(master string, slaves []string) (*DB, error) {
-	d := &DB{}
-	// 如果沒有主從來源就建立一個最主要的連線。
-	if len(slaves) == 0 {
-		db, err := openDatabase(master)
-		if err != nil {
-			return d, err
+// 當有傳入 Slave 的權重時，預設的負載平衡器會是 `WeightedBalancer` 而不是 `RoundRobinBalancer`。
+// 有啟用故障轉移時，主要資料庫無法連線並不會中止建立，而是會以唯讀模式開始。
+func newDatabase(config Config) (*DB, error) {
+	d := &DB{lock: &sync.RWMutex{}, reloadLock: &sync.Mutex{}, dialect: config.Dialect, balancer: config.Balancer, config: config, metrics: config.Metrics, masterTables: make(map[string]bool), work: newWorkTracker()}
+	d.addMasterTables(config.MasterTables...)
+	if d.balancer == nil {
+		d.balancer = &RoundRobinBalancer{}
+		if len(config.Weights) != 0 {
+			d.balancer = &WeightedBalancer{}
 		}
-		d.master = &connection{
-			db:             db,
-			dataSourceName: master,
+	}
+	// 不論有沒有主從來源都需要一個最主要的連線，
+	// 這同時也是所有 Slave 都不健康時的讀取來源。
+	db, masterErr := openDatabase(config.Master, config.MasterPool, config)
+	if masterErr != nil {
+		if config.Failover == nil || db == nil {
+			return d, masterErr
 		}
-		return d, nil
+		d.readOnly = true
 	}
-	d.hasSlave = true
+	d.master = newConnection(db, config.Master, config)
 	// 連線到 Slave 資料庫。
-	for _, v := range slaves {
-		db, err := openDatabase(v)
+	for _, v := range config.Slaves {
+		db, err := openDatabase(v, config.SlavePool, config)
 		if err != nil {
 			return d, err
 		}
-		d.slaves = append(d.slaves, &connection{
-			db:             db,
-			dataSourceName: v,
-		})
+		d.slaves = append(d.slaves, newConnection(db, v, config))
+	}
+	if config.Failover != nil {
+		d.startFailover(*config.Failover)
+		if masterErr != nil {
+			config.Failover.emit(FailoverEvent{Type: MasterDown, DataSourceName: config.Master, Err: masterErr})
+		}
 	}
 	return d, nil
 }
 
-// roundRobin 會輪詢資料庫連線來避免不斷地呼叫同個資料庫連線。
-// 簡單來說就是個簡易型的負載平衡器。
-func (d *DB) roundRobin(pool []*connection, currentIndex int) (index int) {
-	length := len(pool) - 1
-	index = currentIndex + 1
-	if index > length {
-		index = 0
+// getMaster 會回傳目前的主要資料庫連線，主要資料庫可能會在故障轉移時被備用資料庫取代。
+func (d *DB) getMaster() *connection {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.master
+}
+
+// getSlaves 會回傳目前所有的 Slave 資料庫連線，Slave 可能會在重新載入時被新增或移除。
+func (d *DB) getSlaves() []*connection {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.slaves
+}
+
+// isReadOnly 會回傳資料庫目前是否因為主要資料庫無法連線而處於唯讀模式。
+func (d *DB) isReadOnly() bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.readOnly
+}
+
+// inTransaction 會回傳這個資料庫來源是否為交易中的副本。
+func (d *DB) inTransaction() bool {
+	return d.getMaster().pinned() != nil
+}
+
+// withTransaction 會回傳一個共用相同連線，但所有指令都會在指定交易中執行的資料庫來源副本，
+// `conn` 是開始這段交易的連線，唯讀交易的連線可能會是 Slave。
+func (d *DB) withTransaction(conn *connection, tx *sql.Tx, done func()) *DB {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	newDB := *d
+	conn.lock.RLock()
+	newMaster := *conn
+	conn.lock.RUnlock()
+	newMaster.tx = tx
+	newDB.master = &newMaster
+	newDB.endTx = done
+	newDB.hooks = &txHooks{}
+	return &newDB
+}
+
+// setBalancer 會替換 Slave 資料庫的負載平衡器。
+func (d *DB) setBalancer(balancer Balancer) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.balancer = balancer
+}
+
+// getSlave 會透過負載平衡器取得一個可用的 Slave 資料庫連線，不健康、延遲過高或斷路中的連線會被略過。
+// 如果所有的 Slave 都無法使用，則會改從主要資料庫讀取。
+func (d *DB) getSlave() *connection {
+	for {
+		var candidates []*connection
+		var replicas []Replica
+		for _, v := range d.getSlaves() {
+			if !v.available() || !v.breaker.ready() {
+				continue
+			}
+			candidates = append(candidates, v)
+			replicas = append(replicas, Replica{
+				DataSourceName: v.dataSourceName,
+				Weight:         v.weight,
+				InFlight:       v.pending(),
+			})
+		}
+		if len(candidates) == 0 {
+			return d.getMaster()
+		}
+		d.lock.RLock()
+		balancer := d.balancer
+		d.lock.RUnlock()
+		slave := candidates[balancer.Next(replicas)]
+		// 其他的指令可能已經搶先取得了這個 Slave 的試探資格，這時就重新選擇一個 Slave。
+		if slave.breaker.tryAcquire() {
+			return slave
+		}
 	}
-	return
 }
 
-// getSlave 會取得一個可用的 Slave 資料庫連線。
-func (d *DB) getSlave() (db *sql.DB) {
-	index := d.roundRobin(d.slaves, d.lastSlaveIndex)
-	db = d.slaves[index].db
-	// Set the last index.
-	d.lastSlaveIndex = index
-	return
+// addMasterTables 會將資料表格加入到必須從主要資料庫讀取的資料表格清單中。
+func (d *DB) addMasterTables(tables ...string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.masterTables == nil {
+		d.masterTables = make(map[string]bool)
+	}
+	for _, v := range tables {
+		d.masterTables[strings.ToLower(v)] = true
+	}
+}
+
+// routesToMaster 會回傳讀取的資料表格中是否有必須從主要資料庫讀取的資料表格，
+// 帶有資料庫名稱的資料表格（例如：`db.Users`）會同時以完整名稱與資料表格名稱比對。
+func (d *DB) routesToMaster(tables []string) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if len(d.masterTables) == 0 {
+		return false
+	}
+	for _, v := range tables {
+		v = strings.ToLower(v)
+		if d.masterTables[v] || d.masterTables[v[strings.LastIndex(v, ".")+1:]] {
+			return true
+		}
+	}
+	return false
 }
 
 // getDB 會基於 SQL 查詢指令來取得一個適用的資料庫連線，這會被用在讀／寫區分的資料庫上。
-func (d *DB) getDB(query ...string) (db *sql.DB) {
-	if len(query) == 0 || !d.hasSlave {
-		db = d.master.db
-		return
+// 當 `useMaster` 為 `true` 或讀取了必須從主要資料庫讀取的資料表格時，即使是讀取指令也會使用主要資料庫，
+// 但在唯讀模式中仍會從 Slave 讀取。
+func (d *DB) getDB(query string, useMaster bool) *connection {
+	if len(d.getSlaves()) == 0 {
+		return d.getMaster()
+	}
+	info := classify(query)
+	if !info.read {
+		return d.getMaster()
+	}
+	if (useMaster || d.routesToMaster(info.tables)) && !d.isReadOnly() {
+		return d.getMaster()
 	}
-	action := strings.Split(query[0], " ")[0]
-	switch action {
-	case "SELECT":
-		db = d.getSlave()
-	default:
-		db = d.master.db
+	return d.getSlave()
+}
+
+// checkWritable 會在唯讀模式中替寫入指令回傳 `ErrReadOnly`，讓寫入不需要等待無法連線的主要資料庫逾時。
+func (d *DB) checkWritable(query string) error {
+	if !isRead(query) && d.isReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// Begin 會基於目前的資料庫連線來開始一段新的交易過程，當上下文被取消時交易會自動被回溯。
+// 唯讀交易（`opts.ReadOnly`）會在 Slave 上開始，除非 `useMaster` 為 `true`，但在唯讀模式中仍會使用 Slave。
+// 其他的交易在唯讀模式中會直接回傳 `ErrReadOnly`，正在關閉時則會回傳 `ErrShutdown`。
+// 回傳的是開始這段交易的連線，回傳的函式則必須在交易結束時呼叫。
+func (d *DB) begin(ctx context.Context, opts *sql.TxOptions, useMaster bool) (*connection, *sql.Tx, func(), error) {
+	conn := d.getMaster()
+	if opts != nil && opts.ReadOnly {
+		if len(d.getSlaves()) != 0 && (!useMaster || d.isReadOnly()) {
+			conn = d.getSlave()
+		}
+	} else if d.isReadOnly() {
+		return nil, nil, nil, ErrReadOnly
+	}
+	done, err := d.work.add()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tx, err := conn.db.BeginTx(ctx, opts)
+	// 開始交易的結果也會被記錄，這樣作為試探指令的唯讀交易才能夠關閉斷路器。
+	conn.breaker.record(err)
+	if err != nil {
+		done()
+		return nil, nil, nil, err
 	}
-	return
+	return conn, tx, conn.trackTx(done), nil
 }
 
-// Begin 會基於目前的資料庫連線來開始一段新的交易過程。
-func (d *DB) begin() (*sql.Tx, error) {
-	return d.master.db.Begin()
+// beginRead 會在適合執行這個讀取指令的連線上開始一段交易，這會被用在必須於同個連線中執行的讀取指令，
+// 所以在唯讀模式中仍然能夠使用。回傳的是帶有這段交易的連線副本，回傳的函式則必須在交易結束時呼叫。
+func (d *DB) beginRead(ctx context.Context, query string, useMaster bool) (*connection, func(), error) {
+	done, err := d.work.add()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := d.getDB(query, useMaster)
+	tx, err := conn.db.BeginTx(ctx, nil)
+	conn.breaker.record(err)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	conn.lock.RLock()
+	newConn := *conn
+	conn.lock.RUnlock()
+	newConn.tx = tx
+	return &newConn, conn.trackTx(done), nil
 }
 
-// Rollback 會回溯交易時所發生的事情。
-func (d *DB) rollback() error {
+// beginSavepoint 會在目前的交易中建立一個儲存點，並回傳代表這層巢狀交易的資料庫來源副本。
+func (d *DB) beginSavepoint(ctx context.Context) (*DB, error) {
+	newDB := d.withTransaction(d.master, d.master.tx, nil)
+	newDB.savepoint = d.savepoint + 1
+	newDB.hooks.parent = d.hooks
+	if _, err := d.master.tx.ExecContext(ctx, "SAVEPOINT "+newDB.savepointName()); err != nil {
+		return nil, err
+	}
+	return newDB, nil
+}
+
+// savepointName 會回傳這層巢狀交易所使用的儲存點名稱。
+func (d *DB) savepointName() string {
+	return "sp_" + strconv.Itoa(d.savepoint)
+}
+
+// endSavepoint 會以指定的指令結束這層巢狀交易，外層的交易則不會受到影響。
+func (d *DB) endSavepoint(ctx context.Context, command string) error {
+	if _, err := d.master.tx.ExecContext(ctx, command+" "+d.savepointName()); err != nil {
+		return err
+	}
+	d.master.tx = nil
+	return nil
+}
+
+// Rollback 會回溯交易時所發生的事情，巢狀交易則只會回溯到這層交易開始時的儲存點。
+func (d *DB) rollback(ctx context.Context) error {
 	if d.master.tx == nil {
 		return ErrUnbegunTransaction
 	}
+	if d.savepoint > 0 {
+		if err := d.endSavepoint(ctx, "ROLLBACK TO SAVEPOINT"); err != nil {
+			return err
+		}
+		d.hooks.run(false)
+		return nil
+	}
 	err := d.master.tx.Rollback()
+	// 不論成功與否交易都已經結束了。
+	d.finishTx()
 	if err != nil {
 		return err
 	}
 	d.master.tx = nil
+	d.hooks.run(false)
 	return nil
 }
 
-// Commit 會結束一個交易過程並保存其變更為永久資料。
-func (d *DB) commit() error {
+// finishTx 會通知工作追蹤器這段交易已經結束。
+func (d *DB) finishTx() {
+	if d.endTx != nil {
+		d.endTx()
+	}
+}
+
+// Commit 會結束一個交易過程並保存其變更為永久資料，巢狀交易則只會釋放這層交易的儲存點，
+// 變更要等到最外層的交易提交時才會被保存。
+func (d *DB) commit(ctx context.Context) error {
 	if d.master.tx == nil {
 		return ErrUnbegunTransaction
 	}
+	if d.savepoint > 0 {
+		if err := d.endSavepoint(ctx, "RELEASE SAVEPOINT"); err != nil {
+			return err
+		}
+		d.hooks.release()
+		return nil
+	}
 	err := d.master.tx.Commit()
+	d.finishTx()
 	if err != nil {
 		return err
 	}
 	d.master.tx = nil
+	d.hooks.run(true)
 	return nil
 }
 
 // Ping 會以 ping 來檢查所有的資料庫連線（包括 Slave 連線）。
-func (d *DB) ping() error {
+func (d *DB) ping(ctx context.Context) error {
 	var err error
-	err = d.master.db.Ping()
+	err = d.getMaster().db.PingContext(ctx)
 	if err != nil {
 		return err
 	}
-	for _, v := range d.slaves {
-		err = v.db.Ping()
+	for _, v := range d.getSlaves() {
+		err = v.db.PingContext(ctx)
 		if err != nil {
 			return err
 		}
@@ -148,59 +505,100 @@ func (d *DB) ping() error {
 	return nil
 }
 
-// Disconnect 會斷開所有連線（包括 Slave 連線）。
+// Disconnect 會斷開所有連線（包括 Slave 連線），並停止正在執行的健康檢查、複寫延遲與故障轉移監控。
+// 所有已快取的已準備指令也會一併被關閉。
 func (d *DB) disconnect() error {
-	var err error
-	err = d.master.db.Close()
-	if err != nil {
-		return err
+	var errs []error
+	d.stopHealthCheck()
+	d.stopLagMonitor()
+	d.stopFailover()
+	// 即使其中一個連線池關閉失敗，也會繼續關閉其餘的連線池。
+	master := d.getMaster()
+	master.stmts.clear()
+	errs = append(errs, master.db.Close())
+	for _, v := range d.getSlaves() {
+		v.stmts.clear()
+		errs = append(errs, v.db.Close())
 	}
-	for _, v := range d.slaves {
-		err = v.db.Close()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return joinErrors(errs...)
 }
 
-// Connect 會重新連接所有資料庫連線（包括 Slave 連線）。
+// Connect 會以新的連線池重新連接所有資料庫連線（包括 Slave 連線），舊的連線池會在執行中的指令與尚未結束的交易結束後被關閉。
 func (d *DB) connect() error {
-	db, err := sql.Open("mysql", d.master.dataSourceName)
-	if err != nil {
-		return err
-	}
-	d.master.db = db
-	for k, v := range d.slaves {
-		db, err := sql.Open("mysql", v.dataSourceName)
-		if err != nil {
-			return err
-		}
-		d.slaves[k].db = db
-	}
-	return nil
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	d.lock.RLock()
+	config := d.config
+	d.lock.RUnlock()
+	config.PingOnStartup = false
+	return d.replaceConnections(config, nil)
 }
 
-// Prepare 會準備 SQL 查詢指令。
-func (d *DB) prepare(query string) (*sql.Stmt, error) {
-	if d.master.tx != nil {
-		return d.master.tx.Prepare(query)
+// Prepare 會在適用的連線上準備 SQL 查詢指令，有啟用已準備指令快取時會優先使用快取。
+// 執行這個指令的連線已經被計入執行中的指令數量，呼叫者必須在用完指令後呼叫 `close`。
+// 當 `useMaster` 為 `true` 時，即使是讀取指令也會在主要資料庫上準備。
+func (d *DB) prepare(ctx context.Context, query string, useMaster bool) (*statement, error) {
+	conn := d.getMaster()
+	// 交易中的指令已經被交易本身追蹤，所以即使正在關閉也仍然能夠執行。
+	done := func() {}
+	if conn.pinned() == nil {
+		if err := d.checkWritable(query); err != nil {
+			return nil, err
+		}
+		var err error
+		if done, err = d.work.add(); err != nil {
+			return nil, err
+		}
+		conn = d.getDB(query, useMaster)
 	}
-	return d.getDB(query).Prepare(query)
+	conn.acquire()
+	stmt, err := conn.prepare(ctx, query)
+	if err != nil {
+		conn.release()
+		conn.breaker.record(err)
+		done()
+		return nil, err
+	}
+	stmt.done = done
+	return stmt, nil
 }
 
 // Exec 會執行 SQL 查詢指令並且回傳一個原生結果表示影響的行列數和插入的編號。
-func (d *DB) exec(query string, args ...interface{}) (sql.Result, error) {
-	if d.master.tx != nil {
-		return d.master.tx.Exec(query, args...)
+func (d *DB) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if pinned := d.getMaster().pinned(); pinned != nil {
+		return pinned.ExecContext(ctx, query, args...)
+	}
+	if err := d.checkWritable(query); err != nil {
+		return nil, err
+	}
+	done, err := d.work.add()
+	if err != nil {
+		return nil, err
 	}
-	return d.getDB(query).Exec(query, args...)
+	defer done()
+	conn := d.getDB(query, false).acquire()
+	defer conn.release()
+	res, err := conn.db.ExecContext(ctx, query, args...)
+	conn.breaker.record(err)
+	return res, err
 }
 
 // Query 會執行 SQL 查詢指令並且回傳一個原生的行列結果供後續掃描列出。
-func (d *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
-	if d.master.tx != nil {
-		return d.master.tx.Query(query, args...)
+func (d *DB) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if pinned := d.getMaster().pinned(); pinned != nil {
+		return pinned.QueryContext(ctx, query, args...)
+	}
+	if err := d.checkWritable(query); err != nil {
+		return nil, err
+	}
+	done, err := d.work.add()
+	if err != nil {
+		return nil, err
 	}
-	return d.getDB(query).Query(query, args...)
+	defer done()
+	conn := d.getDB(query, false).acquire()
+	defer conn.release()
+	rows, err := conn.db.QueryContext(ctx, query, args...)
+	conn.breaker.record(err)
+	return rows, err
 }
@@ -0,0 +1,455 @@
+package reiner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect 是資料庫的 SQL 方言，這決定了建置出來的 SQL 指令語法與開啟連線時所使用的驅動程式。
+// 建置系統總是以 `?` 作為參數佔位符號建置指令，並在最後才依照方言轉換成相對應的佔位符號。
+type Dialect interface {
+	// DriverName 是透過 `database/sql` 開啟連線時所使用的驅動程式名稱。
+	DriverName() string
+	// Placeholder 會回傳第 `index` 個（從 1 開始）參數的佔位符號。
+	Placeholder(index int) string
+	// Quote 會以這個資料庫的識別符號包覆資料表格或欄位名稱。
+	Quote(identifier string) string
+	// Limit 會建置限制筆數的 SQL 指令片段，`offset` 為負數時表示沒有指定偏移。
+	Limit(offset, count int) string
+	// Upsert 會建置插入資料重複時改為更新指定欄位的 SQL 指令片段，
+	// `conflictColumns` 是用來判斷是否重複的欄位，`lastInsertIDColumn` 則是重複時作為最後插入編號的欄位。
+	Upsert(conflictColumns, updateColumns []string, lastInsertIDColumn string) string
+	// DataType 會將欄位資料型態（例如：`int`、`varchar`）與其參數（例如：`32`、`2, 1`）轉換成這個資料庫的資料型態。
+	DataType(dataType, args string, unsigned, autoIncrement bool) string
+	// AutoIncrement 會回傳自動遞增欄位的 SQL 指令片段，以資料型態實作自動遞增的資料庫則會回傳空字串。
+	AutoIncrement() string
+	// Comment 會回傳欄位備註的 SQL 指令片段，不支援欄位備註的資料庫會回傳空字串。
+	Comment(comment string) string
+	// DefaultValue 會回傳在 `VALUES` 中表示使用欄位預設值的 SQL 指令片段，不支援的資料庫會回傳空字串。
+	DefaultValue() string
+	// WrapCompound 會包覆以 `UNION` 等組合、並且帶有排序或筆數限制的子指令，這樣它們的排序與筆數限制才不會被套用到組合後的結果上。
+	WrapCompound(query string) string
+	// CalcFoundRows 會回傳讓查詢同時計算不受筆數限制之總筆數的查詢選項，不支援的資料庫會回傳空字串，
+	// 這時 `WithTotalCount` 會改以另一個 `COUNT(*)` 查詢取得總筆數。
+	CalcFoundRows() string
+	// LockTables 會建置以指定方式（例如：`READ`、`WRITE`）鎖定資料表格的 SQL 指令，不支援的資料庫會回傳空字串。
+	LockTables(tables []string, method string) string
+	// UnlockTables 會建置解鎖所有資料表格的 SQL 指令，不支援的資料庫會回傳空字串。
+	UnlockTables() string
+	// Key 會建置資料表格中的主鍵、不重複鍵、索引或外鍵（`PRIMARY KEY`、`UNIQUE KEY`、`INDEX`、`FOREIGN KEY`），
+	// `reference` 是外鍵的 `REFERENCES` 指令片段。如果這個資料庫不支援在資料表格中定義該索引，
+	// 則會改為回傳需要在資料表格建立後另外執行的 SQL 指令。
+	Key(table, typ, name string, columns []string, reference string) (definition, statement string)
+	// TableOptions 會建置資料表格選項（例如：引擎、字符集、備註）的 SQL 指令片段，不支援的選項會被忽略。
+	TableOptions(engine EngineType, charset CharsetType, collation CollationType, comment string) string
+}
+
+// rebind 會將以 `?` 建置的 SQL 指令中的佔位符號依序轉換成方言的佔位符號，位於字串或識別符號中的 `?` 會被略過。
+func rebind(dialect Dialect, query string) string {
+	if dialect.Placeholder(1) == "?" {
+		return query
+	}
+	var builder strings.Builder
+	var quote rune
+	index := 0
+	for _, v := range query {
+		switch {
+		case quote != 0:
+			if v == quote {
+				quote = 0
+			}
+		case v == '\'' || v == '"' || v == '`':
+			quote = v
+		case v == '?':
+			index++
+			builder.WriteString(dialect.Placeholder(index))
+			continue
+		}
+		builder.WriteRune(v)
+	}
+	return builder.String()
+}
+
+// quoteColumns 會以方言的識別符號包覆每個欄位名稱並以逗號串連。
+func quoteColumns(dialect Dialect, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, v := range columns {
+		quoted[i] = dialect.Quote(v)
+	}
+	return strings.Join(quoted, ",")
+}
+
+//=======================================================
+// MySQL
+//=======================================================
+
+// MySQL 是預設的 MySQL 方言。
+type MySQL struct{}
+
+// DriverName 是透過 `database/sql` 開啟連線時所使用的驅動程式名稱。
+func (MySQL) DriverName() string {
+	return "mysql"
+}
+
+// Placeholder 會回傳第 `index` 個參數的佔位符號，MySQL 總是 `?`。
+func (MySQL) Placeholder(index int) string {
+	return "?"
+}
+
+// Quote 會以反引號包覆資料表格或欄位名稱。
+func (MySQL) Quote(identifier string) string {
+	return fmt.Sprintf("`%s`", identifier)
+}
+
+// Limit 會建置 `LIMIT offset, count` 的 SQL 指令片段。
+func (MySQL) Limit(offset, count int) string {
+	if offset < 0 {
+		return fmt.Sprintf("LIMIT %d", count)
+	}
+	return fmt.Sprintf("LIMIT %d, %d", offset, count)
+}
+
+// Upsert 會建置 `ON DUPLICATE KEY UPDATE` 的 SQL 指令片段，MySQL 會以唯一鍵判斷是否重複所以不需要 `conflictColumns`。
+func (MySQL) Upsert(conflictColumns, updateColumns []string, lastInsertIDColumn string) (query string) {
+	query = "ON DUPLICATE KEY UPDATE "
+	if lastInsertIDColumn != "" {
+		query += fmt.Sprintf("%s=LAST_INSERT_ID(%s), ", lastInsertIDColumn, lastInsertIDColumn)
+	}
+	for _, v := range updateColumns {
+		query += fmt.Sprintf("%s = VALUES(%s), ", v, v)
+	}
+	return trim(query)
+}
+
+// DataType 會回傳大寫的資料型態與其參數（例如：`VARCHAR(32)`、`INT(10) UNSIGNED`）。
+func (MySQL) DataType(dataType, args string, unsigned, autoIncrement bool) (query string) {
+	query = strings.ToUpper(dataType)
+	if args != "" {
+		query += fmt.Sprintf("(%s)", args)
+	}
+	if unsigned {
+		query += " UNSIGNED"
+	}
+	return
+}
+
+// AutoIncrement 會回傳 `AUTO_INCREMENT`。
+func (MySQL) AutoIncrement() string {
+	return "AUTO_INCREMENT"
+}
+
+// DefaultValue 會回傳 `DEFAULT`。
+func (MySQL) DefaultValue() string {
+	return "DEFAULT"
+}
+
+// WrapCompound 會以括號包覆子指令。
+func (MySQL) WrapCompound(query string) string {
+	return fmt.Sprintf("(%s)", query)
+}
+
+// CalcFoundRows 會回傳 `SQL_CALC_FOUND_ROWS`，總筆數會在查詢後透過 `FOUND_ROWS()` 取得。
+func (MySQL) CalcFoundRows() string {
+	return "SQL_CALC_FOUND_ROWS"
+}
+
+// LockTables 會建置 `LOCK TABLES 資料表格 方式, ...` 的 SQL 指令。
+func (MySQL) LockTables(tables []string, method string) string {
+	locks := make([]string, len(tables))
+	for k, v := range tables {
+		locks[k] = fmt.Sprintf("%s %s", v, method)
+	}
+	return fmt.Sprintf("LOCK TABLES %s", strings.Join(locks, ", "))
+}
+
+// UnlockTables 會回傳 `UNLOCK TABLES`。
+func (MySQL) UnlockTables() string {
+	return "UNLOCK TABLES"
+}
+
+// Comment 會回傳 `COMMENT '備註'`。
+func (MySQL) Comment(comment string) string {
+	return fmt.Sprintf("COMMENT '%s'", comment)
+}
+
+// Key 會建置能夠直接定義在資料表格中的索引，MySQL 的所有索引都能夠這麼做。
+func (d MySQL) Key(table, typ, name string, columns []string, reference string) (definition, statement string) {
+	switch {
+	// 外鍵的名稱不需要以反引號包覆。
+	case name != "" && reference != "":
+		definition = fmt.Sprintf("%s %s (%s)%s", typ, name, quoteColumns(d, columns), reference)
+	case name != "":
+		definition = fmt.Sprintf("%s %s (%s)", typ, d.Quote(name), quoteColumns(d, columns))
+	default:
+		definition = fmt.Sprintf("%s (%s)%s", typ, quoteColumns(d, columns), reference)
+	}
+	return
+}
+
+// TableOptions 會建置引擎、字符集、校對字符格式與備註的 SQL 指令片段，未指定引擎時會使用 `InnoDB`。
+func (MySQL) TableOptions(engine EngineType, charset CharsetType, collation CollationType, comment string) (query string) {
+	if engine == "" {
+		engine = EngineInnoDB
+	}
+	query = fmt.Sprintf("ENGINE=%s, ", strings.ToUpper(string(engine)))
+	if charset != "" {
+		query += fmt.Sprintf("DEFAULT CHARSET=%s, ", charset)
+	}
+	if collation != "" {
+		query += fmt.Sprintf("COLLATE=%s, ", collation)
+	}
+	if comment != "" {
+		query += fmt.Sprintf("COMMENT='%s', ", comment)
+	}
+	return trim(query)
+}
+
+//=======================================================
+// SQLite
+//=======================================================
+
+// SQLite 是 SQLite 的方言，使用前必須自行匯入註冊為 `sqlite3` 的驅動程式（例如：`github.com/mattn/go-sqlite3`）。
+// 插入資料重複時的更新需要 SQLite 3.24 以上的版本。
+type SQLite struct{}
+
+// DriverName 是透過 `database/sql` 開啟連線時所使用的驅動程式名稱。
+func (SQLite) DriverName() string {
+	return "sqlite3"
+}
+
+// Placeholder 會回傳第 `index` 個參數的佔位符號，SQLite 能夠直接使用 `?`。
+func (SQLite) Placeholder(index int) string {
+	return "?"
+}
+
+// Quote 會以雙引號包覆資料表格或欄位名稱。
+func (SQLite) Quote(identifier string) string {
+	return fmt.Sprintf(`"%s"`, identifier)
+}
+
+// Limit 會建置 `LIMIT count OFFSET offset` 的 SQL 指令片段。
+func (SQLite) Limit(offset, count int) string {
+	return standardLimit(offset, count)
+}
+
+// Upsert 會建置 `ON CONFLICT DO UPDATE` 的 SQL 指令片段，SQLite 不支援以欄位指定最後插入的編號。
+func (SQLite) Upsert(conflictColumns, updateColumns []string, lastInsertIDColumn string) string {
+	return standardUpsert(conflictColumns, updateColumns)
+}
+
+// DataType 會將資料型態轉換成 SQLite 的型態親和性，正整數型態皆為 `INTEGER`，
+// 如此一來 `INTEGER PRIMARY KEY` 欄位就會自動遞增。
+func (SQLite) DataType(dataType, args string, unsigned, autoIncrement bool) string {
+	switch dataType = strings.ToLower(dataType); dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		return "INTEGER"
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return "TEXT"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "BLOB"
+	case "double", "float":
+		return "REAL"
+	case "decimal":
+		return "NUMERIC"
+	}
+	return strings.ToUpper(dataType)
+}
+
+// AutoIncrement 會回傳空字串，因為 SQLite 的 `INTEGER PRIMARY KEY` 本身就會自動遞增。
+func (SQLite) AutoIncrement() string {
+	return ""
+}
+
+// DefaultValue 會回傳空字串，因為 SQLite 不支援在 `VALUES` 中使用 `DEFAULT`。
+func (SQLite) DefaultValue() string {
+	return ""
+}
+
+// WrapCompound 會以 `SELECT * FROM (子指令)` 包覆子指令，因為 SQLite 不允許以括號包覆組合中的子指令。
+func (SQLite) WrapCompound(query string) string {
+	return fmt.Sprintf("SELECT * FROM (%s)", query)
+}
+
+// CalcFoundRows 會回傳空字串，因為 SQLite 沒有 `SQL_CALC_FOUND_ROWS`。
+func (SQLite) CalcFoundRows() string {
+	return ""
+}
+
+// LockTables 會回傳空字串，因為 SQLite 只能夠鎖定整個資料庫。
+func (SQLite) LockTables(tables []string, method string) string {
+	return ""
+}
+
+// UnlockTables 會回傳空字串，因為 SQLite 只能夠鎖定整個資料庫。
+func (SQLite) UnlockTables() string {
+	return ""
+}
+
+// Comment 會回傳空字串，因為 SQLite 不支援欄位備註。
+func (SQLite) Comment(comment string) string {
+	return ""
+}
+
+// Key 會以 `CONSTRAINT` 建置主鍵、不重複鍵與外鍵，索引則會另外透過 `CREATE INDEX` 建立。
+func (d SQLite) Key(table, typ, name string, columns []string, reference string) (definition, statement string) {
+	return standardKey(d, table, typ, name, columns, reference)
+}
+
+// TableOptions 會回傳空字串，因為 SQLite 不支援資料表格選項。
+func (SQLite) TableOptions(engine EngineType, charset CharsetType, collation CollationType, comment string) string {
+	return ""
+}
+
+//=======================================================
+// PostgreSQL
+//=======================================================
+
+// PostgreSQL 是 PostgreSQL 的方言，使用前必須自行匯入註冊為 `postgres` 的驅動程式（例如：`github.com/lib/pq`）。
+// 插入資料重複時的更新必須透過 `OnConflict` 指定判斷重複的欄位。
+type PostgreSQL struct{}
+
+// DriverName 是透過 `database/sql` 開啟連線時所使用的驅動程式名稱。
+func (PostgreSQL) DriverName() string {
+	return "postgres"
+}
+
+// Placeholder 會回傳第 `index` 個參數的佔位符號（例如：`$1`）。
+func (PostgreSQL) Placeholder(index int) string {
+	return fmt.Sprintf("$%d", index)
+}
+
+// Quote 會以雙引號包覆資料表格或欄位名稱。
+func (PostgreSQL) Quote(identifier string) string {
+	return fmt.Sprintf(`"%s"`, identifier)
+}
+
+// Limit 會建置 `LIMIT count OFFSET offset` 的 SQL 指令片段。
+func (PostgreSQL) Limit(offset, count int) string {
+	return standardLimit(offset, count)
+}
+
+// Upsert 會建置 `ON CONFLICT DO UPDATE` 的 SQL 指令片段，PostgreSQL 不支援以欄位指定最後插入的編號。
+func (PostgreSQL) Upsert(conflictColumns, updateColumns []string, lastInsertIDColumn string) string {
+	return standardUpsert(conflictColumns, updateColumns)
+}
+
+// DataType 會將資料型態轉換成 PostgreSQL 的資料型態，自動遞增的欄位會使用 `SERIAL` 或 `BIGSERIAL`。
+// PostgreSQL 沒有非負數的正整數型態，所以 `unsigned` 會被忽略。
+func (PostgreSQL) DataType(dataType, args string, unsigned, autoIncrement bool) string {
+	switch dataType = strings.ToLower(dataType); dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		switch {
+		case autoIncrement && dataType == "bigint":
+			return "BIGSERIAL"
+		case autoIncrement:
+			return "SERIAL"
+		case dataType == "bigint":
+			return "BIGINT"
+		case dataType == "tinyint" || dataType == "smallint":
+			return "SMALLINT"
+		}
+		return "INTEGER"
+	case "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return "TEXT"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "BYTEA"
+	case "datetime":
+		return "TIMESTAMP"
+	case "year":
+		return "SMALLINT"
+	case "double":
+		return "DOUBLE PRECISION"
+	case "float":
+		return "REAL"
+	case "char", "varchar", "bit", "decimal":
+		if args != "" {
+			return fmt.Sprintf("%s(%s)", strings.ToUpper(dataType), args)
+		}
+	}
+	return strings.ToUpper(dataType)
+}
+
+// AutoIncrement 會回傳空字串，因為 PostgreSQL 是以 `SERIAL` 資料型態實作自動遞增。
+func (PostgreSQL) AutoIncrement() string {
+	return ""
+}
+
+// DefaultValue 會回傳 `DEFAULT`。
+func (PostgreSQL) DefaultValue() string {
+	return "DEFAULT"
+}
+
+// WrapCompound 會以括號包覆子指令。
+func (PostgreSQL) WrapCompound(query string) string {
+	return fmt.Sprintf("(%s)", query)
+}
+
+// CalcFoundRows 會回傳空字串，因為 PostgreSQL 沒有 `SQL_CALC_FOUND_ROWS`。
+func (PostgreSQL) CalcFoundRows() string {
+	return ""
+}
+
+// LockTables 會回傳空字串，因為 PostgreSQL 的 `LOCK TABLE` 只能夠在交易中使用，並且會在交易結束時才解鎖。
+func (PostgreSQL) LockTables(tables []string, method string) string {
+	return ""
+}
+
+// UnlockTables 會回傳空字串，因為 PostgreSQL 沒有 `UNLOCK TABLES`。
+func (PostgreSQL) UnlockTables() string {
+	return ""
+}
+
+// Comment 會回傳空字串，因為 PostgreSQL 的欄位備註必須另外透過 `COMMENT ON` 設置。
+func (PostgreSQL) Comment(comment string) string {
+	return ""
+}
+
+// Key 會以 `CONSTRAINT` 建置主鍵、不重複鍵與外鍵，索引則會另外透過 `CREATE INDEX` 建立。
+func (d PostgreSQL) Key(table, typ, name string, columns []string, reference string) (definition, statement string) {
+	return standardKey(d, table, typ, name, columns, reference)
+}
+
+// TableOptions 會回傳空字串，因為 PostgreSQL 不支援這些資料表格選項。
+func (PostgreSQL) TableOptions(engine EngineType, charset CharsetType, collation CollationType, comment string) string {
+	return ""
+}
+
+// standardLimit 會建置 SQLite 與 PostgreSQL 共通的 `LIMIT count OFFSET offset` 指令片段。
+func standardLimit(offset, count int) string {
+	if offset < 0 {
+		return fmt.Sprintf("LIMIT %d", count)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", count, offset)
+}
+
+// standardUpsert 會建置 SQLite 與 PostgreSQL 共通的 `ON CONFLICT (欄位) DO UPDATE SET` 指令片段。
+func standardUpsert(conflictColumns, updateColumns []string) (query string) {
+	query = "ON CONFLICT "
+	if len(conflictColumns) != 0 {
+		query += fmt.Sprintf("(%s) ", strings.Join(conflictColumns, ", "))
+	}
+	query += "DO UPDATE SET "
+	for _, v := range updateColumns {
+		query += fmt.Sprintf("%s = EXCLUDED.%s, ", v, v)
+	}
+	return trim(query)
+}
+
+// standardKey 會建置 SQLite 與 PostgreSQL 共通的索引定義，這兩者都不支援在資料表格中定義普通索引。
+func standardKey(d Dialect, table, typ, name string, columns []string, reference string) (definition, statement string) {
+	if typ == "INDEX" {
+		if name == "" {
+			name = fmt.Sprintf("%s_%s_index", table, strings.Join(columns, "_"))
+		}
+		statement = fmt.Sprintf("CREATE INDEX %s ON %s (%s)", d.Quote(name), d.Quote(table), quoteColumns(d, columns))
+		return
+	}
+	if typ == "UNIQUE KEY" {
+		typ = "UNIQUE"
+	}
+	definition = fmt.Sprintf("%s (%s)%s", typ, quoteColumns(d, columns), reference)
+	if name != "" {
+		definition = fmt.Sprintf("CONSTRAINT %s %s", d.Quote(name), definition)
+	}
+	return
+}
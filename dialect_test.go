@@ -0,0 +1,136 @@
+package reiner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectPostgreSQL(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: PostgreSQL{}})
+	assert.NoError(err)
+
+	subQuery := b.SubQuery().Table("Products").Where("Quantity", ">", 2).Get("UserID")
+	rb, _ := b.Table("Users").Where("ID", "IN", subQuery).Where("Username", "YamiOdymel").Limit(10, 20).Get()
+	assert.Equal("SELECT * FROM Users WHERE ID IN (SELECT UserID FROM Products WHERE Quantity > $1) AND Username = $2 LIMIT 20 OFFSET 10", rb.Query())
+	assert.Equal([]interface{}{2, "YamiOdymel"}, rb.Params())
+
+	rb, _ = b.Table("Users").Where("Username = '?'").Where("Password", "test").Limit(1).Get()
+	assert.Equal("SELECT * FROM Users WHERE Username = '?' AND Password = $1 LIMIT 1", rb.Query())
+
+	rb, _ = b.Table("Users").OnConflict("ID").OnDuplicate([]string{"Username"}).Insert(map[string]interface{}{"ID": 1})
+	assert.Equal("INSERT INTO Users (ID) VALUES ($1) ON CONFLICT (ID) DO UPDATE SET Username = EXCLUDED.Username", rb.Query())
+}
+
+func TestDialectSQLite(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: SQLite{}})
+	assert.NoError(err)
+
+	rb, _ := b.Table("Users").Where("Username", "YamiOdymel").Limit(10, 20).Get()
+	assert.Equal("SELECT * FROM Users WHERE Username = ? LIMIT 20 OFFSET 10", rb.Query())
+	rb, _ = b.Table("Users").OnDuplicate([]string{"Username"}).Insert(map[string]interface{}{"ID": 1})
+	assert.Equal("INSERT INTO Users (ID) VALUES (?) ON CONFLICT DO UPDATE SET Username = EXCLUDED.Username", rb.Query())
+}
+
+func TestDialectMigration(t *testing.T) {
+	assert := assert.New(t)
+	m := newMigration(&DB{dialect: PostgreSQL{}})
+	m.Table("Users", "使用者").
+		Column("ID").BigInt(20).Unsigned().AutoIncrement().Primary().Comment("編號").
+		Column("Username").Varchar(32).Unique().
+		Column("GroupID").Int(10).Foreign("Groups.ID").OnDelete("CASCADE").
+		Column("CreatedAt").DateTime().Index().
+		Unique("uk_name", []string{"Username", "GroupID"})
+	assert.Equal(`CREATE TABLE IF NOT EXISTS "Users" ("ID" BIGSERIAL NOT NULL PRIMARY KEY , "Username" VARCHAR(32) NOT NULL UNIQUE , "GroupID" INTEGER NOT NULL , "CreatedAt" TIMESTAMP NOT NULL, FOREIGN KEY ("GroupID") REFERENCES "Groups" ("ID") ON DELETE CASCADE, CONSTRAINT "uk_name" UNIQUE ("Username","GroupID"))`, m.tableBuilder())
+	assert.Equal([]string{`CREATE INDEX "Users_CreatedAt_index" ON "Users" ("CreatedAt")`}, m.statements)
+
+	m = newMigration(&DB{dialect: SQLite{}})
+	m.Table("Users").Column("ID").Int(10).AutoIncrement().Primary().Column("Avatar").Blob().Nullable()
+	assert.Equal(`CREATE TABLE IF NOT EXISTS "Users" ("ID" INTEGER NOT NULL PRIMARY KEY , "Avatar" BLOB DEFAULT NULL)`, m.tableBuilder())
+}
+
+func TestDialectSQLiteDefault(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: SQLite{}})
+	assert.NoError(err)
+
+	// SQLite 不支援 `DEFAULT`，使用預設值的欄位不同的資料會被分批插入，而自動遞增的欄位則會以 NULL 插入。
+	rb, err := b.Table("Users").InsertMulti([]structUser{
+		{Username: "YamiOdymel"},
+		{ID: 3, Username: "Karisu", Nickname: "Kari"},
+		{Username: "Mashiro", Nickname: "Shiro"},
+	})
+	assert.NoError(err)
+	assert.Len(rb.Chunks, 2)
+	assert.Equal("INSERT INTO Users (ID, Username, Nickname) VALUES (?, ?, ?), (NULL, ?, ?)", rb.Query())
+	assert.Equal([]interface{}{3, "Karisu", "Kari", "Mashiro", "Shiro"}, rb.Params())
+
+	_, err = b.Table("Users").Insert([]structUser{{Username: "YamiOdymel"}, {Username: "Karisu", Nickname: "Kari"}})
+	assert.Equal(ErrUnsupportedDefault, err)
+}
+
+// testSQLiteDialect 是使用假資料庫驅動程式的 SQLite 方言。
+type testSQLiteDialect struct {
+	SQLite
+}
+
+func (testSQLiteDialect) DriverName() string {
+	return "reiner_test"
+}
+
+func TestDialectTotalCount(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: PostgreSQL{}})
+	assert.NoError(err)
+
+	// 不支援 `SQL_CALC_FOUND_ROWS` 的方言會另外以 `COUNT(*)` 查詢總筆數。
+	rb, err := b.SetTrace(true).Table("Users").Where("Username", "YamiOdymel").OrderBy("ID", "ASC").Paginate(2)
+	assert.NoError(err)
+	assert.Equal("SELECT * FROM Users WHERE Username = $1 ORDER BY ID ASC LIMIT 20 OFFSET 20", rb.Query())
+	assert.Len(rb.Traces, 2)
+	assert.Equal("SELECT COUNT(*) FROM (SELECT * FROM Users WHERE Username = $1) AS total_count", rb.Traces[1].Query)
+
+	b, err = NewWithConfig(Config{Dialect: testSQLiteDialect{}, Master: "master-rows"})
+	assert.NoError(err)
+	var users []map[string]interface{}
+	rb, err = b.Table("Users").Bind(&users).Paginate(2)
+	assert.NoError(err)
+	assert.Equal(42, rb.TotalCount)
+	assert.Equal(2, rb.TotalPage)
+}
+
+func TestDialectLock(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: MySQL{}})
+	assert.NoError(err)
+	rb, err := b.SetLockMethod("write").Lock("Users", "Logs")
+	assert.NoError(err)
+	assert.Equal("LOCK TABLES Users WRITE, Logs WRITE", rb.Query())
+	rb, err = b.Unlock()
+	assert.NoError(err)
+	assert.Equal("UNLOCK TABLES", rb.Query())
+
+	b, err = NewWithConfig(Config{Dialect: SQLite{}})
+	assert.NoError(err)
+	_, err = b.SetLockMethod("WRITE").Lock("Users")
+	assert.Equal(ErrUnsupportedLock, err)
+	_, err = b.Unlock()
+	assert.Equal(ErrUnsupportedLock, err)
+}
+
+func TestDialectCompound(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: SQLite{}})
+	assert.NoError(err)
+	banned := b.SubQuery().Table("Banned").OrderBy("CreatedAt", "DESC").Limit(5).Get("Username")
+	rb, _ := b.Table("Users").Where("Age", ">", 18).UnionAll(banned).OrderBy("Username", "ASC").Get("Username")
+	assert.Equal("SELECT Username FROM Users WHERE Age > ? UNION ALL SELECT * FROM (SELECT Username FROM Banned ORDER BY CreatedAt DESC LIMIT 5) ORDER BY Username ASC", rb.Query())
+
+	b, err = NewWithConfig(Config{Dialect: PostgreSQL{}})
+	assert.NoError(err)
+	banned = b.SubQuery().Table("Banned").Where("Level", 1).Limit(5).Get("Username")
+	rb, _ = b.Table("Users").Where("Age", ">", 18).Except(banned).Get("Username")
+	assert.Equal("SELECT Username FROM Users WHERE Age > $1 EXCEPT (SELECT Username FROM Banned WHERE Level = $2 LIMIT 5)", rb.Query())
+}
@@ -0,0 +1,198 @@
+package reiner
+
+import (
+	"context"
+	"time"
+)
+
+// FailoverEventType 是故障轉移事件的種類。
+type FailoverEventType int
+
+const (
+	// MasterDown 表示主要資料庫無法連線，資料庫已經進入唯讀模式。
+	MasterDown FailoverEventType = iota
+	// MasterRecovered 表示主要資料庫已經恢復連線，資料庫已經離開唯讀模式。
+	MasterRecovered
+	// StandbyPromoted 表示備用資料庫已經被提升為主要資料庫，資料庫已經離開唯讀模式。
+	StandbyPromoted
+)
+
+// String 會回傳故障轉移事件種類的名稱。
+func (t FailoverEventType) String() string {
+	switch t {
+	case MasterDown:
+		return "MasterDown"
+	case MasterRecovered:
+		return "MasterRecovered"
+	case StandbyPromoted:
+		return "StandbyPromoted"
+	}
+	return "Unknown"
+}
+
+// FailoverEvent 是故障轉移時每次狀態轉換所發出的事件。
+type FailoverEvent struct {
+	// Type 是事件的種類。
+	Type FailoverEventType
+	// DataSourceName 是事件所涉及的資料庫 DSN，在 `StandbyPromoted` 事件中為新的主要資料庫。
+	DataSourceName string
+	// Err 是導致主要資料庫被視為無法連線的錯誤，僅會在 `MasterDown` 事件中出現。
+	Err error
+	// Time 是事件發生的時間。
+	Time time.Time
+}
+
+// FailoverConfig 是主要資料庫故障轉移的設置。
+type FailoverConfig struct {
+	// Interval 是 ping 主要資料庫的間隔，每次 ping 最多也只會等待這麼久。小於等於零時會使用預設的 5 秒。
+	Interval time.Duration
+	// Threshold 是主要資料庫需要連續失敗幾次才會被視為無法連線，恢復時也需要連續成功相同的次數。
+	Threshold int
+	// Standby 是備用資料庫的 DSN，主要資料庫無法連線時會試著將其提升為主要資料庫。
+	// 這僅會改變 Reiner 寫入的對象，備用資料庫本身必須已經能夠接受寫入。未指定時僅會進入唯讀模式。
+	Standby string
+	// OnEvent 會在每次狀態轉換時被呼叫，這會在背景的 Goroutine 中執行所以不應該阻塞太久。
+	OnEvent func(FailoverEvent)
+}
+
+// emit 會替事件加上時間並且呼叫事件回呼函式。
+func (c FailoverConfig) emit(event FailoverEvent) {
+	if c.OnEvent == nil {
+		return
+	}
+	event.Time = time.Now()
+	c.OnEvent(event)
+}
+
+// failover 會定期 ping 主要資料庫，並在無法連線時切換到唯讀模式或提升備用資料庫。
+type failover struct {
+	config FailoverConfig
+	// failures 與 successes 是主要資料庫連續 ping 失敗與成功的次數，僅會在監控的 Goroutine 中存取。
+	failures  int
+	successes int
+	// promoted 表示備用資料庫是否已經被提升過，備用資料庫僅會被提升一次。
+	promoted bool
+	stop     chan struct{}
+}
+
+// startFailover 會在背景開始定期檢查主要資料庫，如果已經有正在執行的故障轉移監控則會先停止它。
+func (d *DB) startFailover(config FailoverConfig) {
+	d.stopFailoverMonitor()
+	if config.Interval <= 0 {
+		config.Interval = defaultCheckInterval
+	}
+	if config.Threshold < 1 {
+		config.Threshold = 1
+	}
+	f := &failover{
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	d.lock.Lock()
+	d.failover = f
+	d.lock.Unlock()
+
+	go every(config.Interval, f.stop, func() {
+		d.checkMaster(f)
+	})
+}
+
+// stopFailover 會停止正在背景執行的故障轉移監控，並且離開唯讀模式。
+func (d *DB) stopFailover() {
+	d.stopFailoverMonitor()
+	d.setReadOnly(false)
+}
+
+// stopFailoverMonitor 會停止正在背景執行的故障轉移監控，但不會改變唯讀模式。
+func (d *DB) stopFailoverMonitor() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.failover == nil {
+		return
+	}
+	close(d.failover.stop)
+	d.failover = nil
+}
+
+// setReadOnly 會進入或離開唯讀模式。
+func (d *DB) setReadOnly(readOnly bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.readOnly = readOnly
+}
+
+// checkMaster 會 ping 主要資料庫並且依照結果轉換狀態。主要資料庫連續失敗 `Threshold` 次後會進入唯讀模式，
+// 在唯讀模式中如果主要資料庫連續成功 `Threshold` 次則會離開唯讀模式，否則會試著提升備用資料庫。
+func (d *DB) checkMaster(f *failover) {
+	master := d.getMaster()
+	ctx, cancel := context.WithTimeout(context.Background(), f.config.Interval)
+	err := master.db.PingContext(ctx)
+	cancel()
+	master.markHealth(err, f.config.Threshold)
+	if err != nil {
+		f.failures++
+		f.successes = 0
+	} else {
+		f.successes++
+		f.failures = 0
+	}
+
+	if !d.isReadOnly() {
+		if f.failures < f.config.Threshold {
+			return
+		}
+		d.setReadOnly(true)
+		f.config.emit(FailoverEvent{Type: MasterDown, DataSourceName: master.dataSourceName, Err: err})
+	}
+	if err == nil {
+		if f.successes < f.config.Threshold {
+			return
+		}
+		f.successes = 0
+		d.setReadOnly(false)
+		f.config.emit(FailoverEvent{Type: MasterRecovered, DataSourceName: master.dataSourceName})
+		return
+	}
+	if f.config.Standby != "" && !f.promoted {
+		d.promote(f, master)
+	}
+}
+
+// promote 會試著連線到備用資料庫，並且在能夠連線時將其提升為主要資料庫並離開唯讀模式。
+// 如果 `failed` 在這之前已經被重新載入或重新連線替換掉了，就不會提升備用資料庫。
+// 原本的主要資料庫連線池會在背景關閉，因為關閉時會等待仍在執行中的指令。
+func (d *DB) promote(f *failover, failed *connection) {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	d.lock.RLock()
+	config := d.config
+	replaced := d.master != failed
+	d.lock.RUnlock()
+	if replaced {
+		return
+	}
+	config.PingOnStartup = true
+	config.ConnectTimeout = f.config.Interval
+	db, err := openDatabase(f.config.Standby, config.MasterPool, config)
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		return
+	}
+	standby := newConnection(db, f.config.Standby, config)
+
+	d.lock.Lock()
+	old := d.master
+	d.master = standby
+	// 同時更新設置，這樣之後透過 `Connect` 或 `Reload` 重新建立連線時才不會換回已經失效的主要資料庫。
+	d.config.Master = f.config.Standby
+	d.readOnly = false
+	d.lock.Unlock()
+
+	f.promoted = true
+	f.failures = 0
+	f.successes = 0
+	go old.retire()
+	f.config.emit(FailoverEvent{Type: StandbyPromoted, DataSourceName: standby.dataSourceName})
+}
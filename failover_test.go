@@ -0,0 +1,292 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toggleConnector 是一個能夠切換是否可以連線的假資料庫連線器，用以測試 ping 的結果。
+type toggleConnector struct {
+	down int32
+}
+
+func (c *toggleConnector) setDown(down bool) {
+	var v int32
+	if down {
+		v = 1
+	}
+	atomic.StoreInt32(&c.down, v)
+}
+
+func (c *toggleConnector) Connect(context.Context) (driver.Conn, error) {
+	if atomic.LoadInt32(&c.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return toggleConn{}, nil
+}
+
+func (c *toggleConnector) Driver() driver.Driver {
+	return nil
+}
+
+type toggleConn struct {
+	// noInsertID 表示執行結果不支援 `LastInsertId`。
+	noInsertID bool
+	// rows 表示查詢會回傳 `toggleRows`，否則查詢都會失敗。
+	rows bool
+}
+
+// preparedQueries 記錄了所有在假資料庫上準備過的指令。
+var preparedQueries struct {
+	sync.Mutex
+	list []string
+}
+
+// xaCommitFails 為 `1` 時 `XA COMMIT` 會因為連線中斷而失敗。
+var xaCommitFails int32
+
+func (c toggleConn) Prepare(query string) (driver.Stmt, error) {
+	preparedQueries.Lock()
+	preparedQueries.list = append(preparedQueries.list, query)
+	preparedQueries.Unlock()
+	if atomic.LoadInt32(&xaCommitFails) == 1 && strings.HasPrefix(query, "XA COMMIT") {
+		return nil, driver.ErrBadConn
+	}
+	return toggleStmt{query: query, noInsertID: c.noInsertID, rows: c.rows}, nil
+}
+
+func (toggleConn) Close() error {
+	return nil
+}
+
+func (toggleConn) Begin() (driver.Tx, error) {
+	return toggleTx{}, nil
+}
+
+// lastTxOptions 是最後一次在假資料庫上開始交易時的選項。
+var lastTxOptions atomic.Value
+
+func (toggleConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	lastTxOptions.Store(opts)
+	return toggleTx{}, nil
+}
+
+// toggleTx 是假的交易，提交與回溯的次數會被記錄在 `txCommits` 與 `txRollbacks` 中。
+type toggleTx struct{}
+
+var txCommits, txRollbacks int64
+
+// txCommitFails 為 `1` 時提交交易會失敗。
+var txCommitFails int32
+
+func (toggleTx) Commit() error {
+	if atomic.LoadInt32(&txCommitFails) == 1 {
+		return errors.New("commit failed")
+	}
+	atomic.AddInt64(&txCommits, 1)
+	return nil
+}
+
+func (toggleTx) Rollback() error {
+	atomic.AddInt64(&txRollbacks, 1)
+	return nil
+}
+
+type toggleStmt struct {
+	query      string
+	noInsertID bool
+	rows       bool
+}
+
+func (toggleStmt) Close() error {
+	return nil
+}
+
+func (toggleStmt) NumInput() int {
+	return -1
+}
+
+func (s toggleStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.noInsertID {
+		return driver.RowsAffected(1), nil
+	}
+	return toggleResult{}, nil
+}
+
+// toggleResult 是假的執行結果，最後插入的編號永遠是 `10`。
+type toggleResult struct{}
+
+func (toggleResult) LastInsertId() (int64, error) {
+	return 10, nil
+}
+
+func (toggleResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+func (s toggleStmt) Query([]driver.Value) (driver.Rows, error) {
+	if !s.rows {
+		return nil, errors.New("not implemented")
+	}
+	return &toggleRows{count: strings.HasPrefix(s.query, "SELECT COUNT(*)")}, nil
+}
+
+// toggleRows 是假的查詢結果，`COUNT(*)` 查詢的結果永遠是 `42`，其他的查詢則沒有任何資料。
+type toggleRows struct {
+	count bool
+	done  bool
+}
+
+func (r *toggleRows) Columns() []string {
+	if r.count {
+		return []string{"COUNT(*)"}
+	}
+	return []string{"ID"}
+}
+
+func (r *toggleRows) Close() error {
+	return nil
+}
+
+func (r *toggleRows) Next(dest []driver.Value) error {
+	if !r.count || r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+	return nil
+}
+
+func TestFailoverReadOnly(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	connector := &toggleConnector{}
+	connector.setDown(true)
+	d.master.db = sql.OpenDB(connector)
+
+	var events []FailoverEvent
+	f := &failover{config: FailoverConfig{Interval: time.Second, Threshold: 2, OnEvent: func(e FailoverEvent) {
+		events = append(events, e)
+	}}}
+
+	d.checkMaster(f)
+	assert.False(d.isReadOnly())
+	d.checkMaster(f)
+	assert.True(d.isReadOnly())
+	assert.Len(events, 1)
+	assert.Equal(MasterDown, events[0].Type)
+	assert.Error(events[0].Err)
+	assert.False(d.status()[0].Healthy)
+
+	_, err := d.exec(context.Background(), "INSERT INTO Users (Username) VALUES (?)", "YamiOdymel")
+	assert.Equal(ErrReadOnly, err)
+	_, err = d.prepare(context.Background(), "UPDATE Users SET Username = ?", true)
+	assert.Equal(ErrReadOnly, err)
+	_, _, _, err = d.begin(context.Background(), nil, false)
+	assert.Equal(ErrReadOnly, err)
+	assert.Equal(d.slaves[0], d.getDB("SELECT * FROM Users", true))
+
+	connector.setDown(false)
+	d.checkMaster(f)
+	assert.True(d.isReadOnly())
+	d.checkMaster(f)
+	assert.False(d.isReadOnly())
+	assert.Len(events, 2)
+	assert.Equal(MasterRecovered, events[1].Type)
+	assert.Equal(d.master, d.getDB("SELECT * FROM Users", true))
+}
+
+func TestFailoverStandbyUnavailable(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	connector := &toggleConnector{}
+	connector.setDown(true)
+	d.master.db = sql.OpenDB(connector)
+	master := d.master
+
+	f := &failover{config: FailoverConfig{Interval: time.Second, Threshold: 1, Standby: "root:root@tcp(127.0.0.1:1)/standby"}}
+	d.checkMaster(f)
+	assert.True(d.isReadOnly())
+	assert.False(f.promoted)
+	assert.Equal(master, d.getMaster())
+
+	d.stopFailover()
+	assert.False(d.isReadOnly())
+}
+
+func TestFailoverPromoteConnect(t *testing.T) {
+	assert := assert.New(t)
+	d, err := newDatabase(Config{Dialect: testDialect{}, Master: "master-down", Slaves: []string{"slave"}})
+	assert.NoError(err)
+
+	f := &failover{config: FailoverConfig{Interval: time.Second, Threshold: 1, Standby: "standby"}}
+	d.checkMaster(f)
+	assert.True(f.promoted)
+	assert.Equal("standby", d.getMaster().dataSourceName)
+
+	// 重新連線時會沿用已經提升的備用資料庫，而不是換回失效的主要資料庫。
+	promoted := d.getMaster()
+	assert.NoError(d.connect())
+	assert.Equal("standby", d.getMaster().dataSourceName)
+	assert.False(d.isReadOnly())
+
+	// 失效的主要資料庫已經被替換時，就不會再提升備用資料庫。
+	f = &failover{config: FailoverConfig{Interval: time.Second, Threshold: 1, Standby: "standby2"}}
+	d.promote(f, promoted)
+	assert.False(f.promoted)
+	assert.Equal("standby", d.getMaster().dataSourceName)
+}
+
+func TestFailoverStartup(t *testing.T) {
+	assert := assert.New(t)
+	var events []FailoverEvent
+	b, err := NewWithConfig(Config{
+		Master:         "root:root@tcp(127.0.0.1:1)/master",
+		Slaves:         []string{"root:root@tcp(127.0.0.1:1)/slave"},
+		ConnectTimeout: time.Second,
+		Failover: &FailoverConfig{Interval: time.Hour, OnEvent: func(e FailoverEvent) {
+			events = append(events, e)
+		}},
+	})
+	assert.NoError(err)
+	assert.False(b.ReadOnly())
+
+	b, err = NewWithConfig(Config{
+		Master:         "root:root@tcp(127.0.0.1:1)/master",
+		ConnectTimeout: time.Second,
+		PingOnStartup:  true,
+		Failover: &FailoverConfig{Interval: time.Hour, OnEvent: func(e FailoverEvent) {
+			events = append(events, e)
+		}},
+	})
+	assert.NoError(err)
+	assert.True(b.ReadOnly())
+	assert.Len(events, 1)
+	assert.Equal(MasterDown, events[0].Type)
+
+	_, err = b.Table("Users").Insert(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.Equal(ErrReadOnly, err)
+	assert.NoError(b.Disconnect())
+	assert.False(b.ReadOnly())
+}
+
+func TestFailoverDefaultInterval(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master", Failover: &FailoverConfig{Standby: "standby"}})
+	assert.NoError(err)
+	b.db.lock.RLock()
+	assert.Equal(defaultCheckInterval, b.db.failover.config.Interval)
+	assert.Equal(1, b.db.failover.config.Threshold)
+	b.db.lock.RUnlock()
+	b.StopFailover()
+}
@@ -0,0 +1,169 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ConnectionStatus 是單個資料庫連線的狀態資訊。
+type ConnectionStatus struct {
+	// Name 是這個連線在指標中的名稱，主要資料庫會是 `master`，Slave 則會依照順序是 `slave_0`、`slave_1`⋯⋯。
+	Name string
+	// DataSourceName 是這個連線的資料來源名稱。
+	DataSourceName string
+	// Master 表示這是否為主要資料庫的連線。
+	Master bool
+	// Healthy 表示這個連線是否健康，不健康的 Slave 連線不會被用來讀取資料。
+	Healthy bool
+	// LastCheck 是最後一次執行健康檢查的時間，尚未檢查過時會是零值。
+	LastCheck time.Time
+	// Lag 是最後一次檢查時 Slave 落後主要資料庫的時間，僅在啟用複寫延遲監控時才會更新。
+	Lag time.Duration
+	// Lagging 表示 Slave 的複寫延遲是否超過限制或無法得知，這樣的 Slave 不會被用來讀取資料。
+	Lagging bool
+	// StatementCacheHits 與 StatementCacheMisses 是已準備指令快取的命中與未命中次數，沒有啟用快取時會是零。
+	StatementCacheHits   uint64
+	StatementCacheMisses uint64
+	// Breaker 是這個連線的斷路器狀態，沒有啟用斷路器時會是 `BreakerClosed`。
+	Breaker BreakerState
+	// Pool 是這個連線池的統計資訊。
+	Pool sql.DBStats
+}
+
+// defaultCheckInterval 是背景檢查沒有指定間隔（或間隔小於等於零）時所使用的預設間隔。
+const defaultCheckInterval = 5 * time.Second
+
+// healthChecker 會定期以 ping 檢查所有 Slave 資料庫連線的健康狀態。
+type healthChecker struct {
+	interval time.Duration
+	// threshold 是不健康的連線需要連續成功幾次才能重新回到輪詢中。
+	threshold int
+	stop      chan struct{}
+}
+
+// healthy 會回傳這個連線目前是否健康。
+func (c *connection) healthy() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.isHealth
+}
+
+// available 會回傳這個連線目前是否能夠用來讀取資料，
+// 連線必須是健康的，而且複寫延遲不能超過限制。
+func (c *connection) available() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.isHealth && !c.lagging
+}
+
+// markHealth 會依照健康檢查的結果更新連線的健康狀態。
+// 只要失敗一次就會被標記為不健康，並且需要連續成功 `threshold` 次才會重新被標記為健康。
+func (c *connection) markHealth(err error, threshold int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastCheck = time.Now()
+	if err != nil {
+		c.isHealth = false
+		c.successCount = 0
+		return
+	}
+	if c.isHealth {
+		return
+	}
+	c.successCount++
+	if c.successCount >= threshold {
+		c.isHealth = true
+		c.successCount = 0
+	}
+}
+
+// status 會回傳這個連線目前的狀態資訊。
+func (c *connection) status(name string) ConnectionStatus {
+	hits, misses := c.stmts.stats()
+	pool := c.db.Stats()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return ConnectionStatus{
+		Name:                 name,
+		DataSourceName:       c.dataSourceName,
+		Master:               name == "master",
+		Healthy:              c.isHealth,
+		LastCheck:            c.lastCheck,
+		Lag:                  c.lag,
+		Lagging:              c.lagging,
+		StatementCacheHits:   hits,
+		StatementCacheMisses: misses,
+		Breaker:              c.breaker.currentState(),
+		Pool:                 pool,
+	}
+}
+
+// startHealthCheck 會在背景開始定期檢查所有 Slave 連線的健康狀態，
+// 如果已經有正在執行的健康檢查則會先停止它。
+func (d *DB) startHealthCheck(interval time.Duration, threshold int) {
+	d.stopHealthCheck()
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	h := &healthChecker{
+		interval:  interval,
+		threshold: threshold,
+		stop:      make(chan struct{}),
+	}
+	d.lock.Lock()
+	d.healthChecker = h
+	d.lock.Unlock()
+
+	go every(h.interval, h.stop, func() {
+		d.checkHealth(h)
+	})
+}
+
+// every 會以指定的間隔不斷地執行傳入的函式，直到 `stop` 被關閉為止，間隔必須大於零。
+func every(interval time.Duration, stop chan struct{}, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// stopHealthCheck 會停止正在背景執行的健康檢查。
+func (d *DB) stopHealthCheck() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.healthChecker == nil {
+		return
+	}
+	close(d.healthChecker.stop)
+	d.healthChecker = nil
+}
+
+// checkHealth 會 ping 每個 Slave 連線並且更新它們的健康狀態，
+// 每次 ping 最多只會等待一個檢查間隔的時間。
+func (d *DB) checkHealth(h *healthChecker) {
+	for _, v := range d.getSlaves() {
+		ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+		err := v.db.PingContext(ctx)
+		cancel()
+		v.markHealth(err, h.threshold)
+	}
+}
+
+// status 會回傳所有連線的狀態資訊，主要資料庫的連線會在第一個。
+func (d *DB) status() []ConnectionStatus {
+	statuses := []ConnectionStatus{d.getMaster().status("master")}
+	for k, v := range d.getSlaves() {
+		statuses = append(statuses, v.status(slaveName(k)))
+	}
+	return statuses
+}
@@ -0,0 +1,96 @@
+package reiner
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDatabase 會建立一個不會真正連線的主從資料庫，用以測試連線的選擇。
+func newTestDatabase(slaves ...string) *DB {
+	d := &DB{lock: &sync.RWMutex{}, reloadLock: &sync.Mutex{}, dialect: MySQL{}, config: Config{Dialect: MySQL{}}, balancer: &RoundRobinBalancer{}, work: newWorkTracker()}
+	db, _ := sql.Open("mysql", "root:root@/master")
+	d.master = newConnection(db, "root:root@/master", d.config)
+	for _, v := range slaves {
+		db, _ := sql.Open("mysql", v)
+		d.slaves = append(d.slaves, newConnection(db, v, d.config))
+	}
+	return d
+}
+
+func TestHealthMark(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestDatabase("root:root@/slave").slaves[0]
+	assert.True(c.healthy())
+
+	c.markHealth(errors.New("timeout"), 2)
+	assert.False(c.healthy())
+	c.markHealth(nil, 2)
+	assert.False(c.healthy())
+	c.markHealth(errors.New("timeout"), 2)
+	c.markHealth(nil, 2)
+	assert.False(c.healthy())
+	c.markHealth(nil, 2)
+	assert.True(c.healthy())
+	assert.False(c.status("slave_0").LastCheck.IsZero())
+}
+
+func TestHealthGetSlave(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave", "root:root@/slave2")
+
+	d.slaves[0].markHealth(errors.New("timeout"), 1)
+	for i := 0; i < 4; i++ {
+		assert.Equal(d.slaves[1], d.getSlave())
+	}
+
+	d.slaves[1].markHealth(errors.New("timeout"), 1)
+	assert.Equal(d.master, d.getSlave())
+	assert.Equal(d.master, d.getDB("SELECT * FROM Users", false))
+
+	d.slaves[0].markHealth(nil, 1)
+	assert.Equal(d.slaves[0], d.getSlave())
+}
+
+func TestHealthLag(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave", "root:root@/slave2")
+
+	d.slaves[0].markLag(30*time.Second, true, 10*time.Second)
+	d.slaves[1].markLag(2*time.Second, true, 10*time.Second)
+	for i := 0; i < 4; i++ {
+		assert.Equal(d.slaves[1], d.getSlave())
+	}
+	status := d.status()
+	assert.Equal(30*time.Second, status[1].Lag)
+	assert.True(status[1].Lagging)
+	assert.False(status[2].Lagging)
+
+	d.slaves[1].markLag(0, false, 10*time.Second)
+	assert.Equal(d.master, d.getSlave())
+}
+
+func TestHealthCheckDefaultInterval(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	d.startHealthCheck(0, 0)
+	defer d.stopHealthCheck()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	assert.Equal(defaultCheckInterval, d.healthChecker.interval)
+	assert.Equal(1, d.healthChecker.threshold)
+}
+
+func TestLagMonitorDefaultInterval(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	d.startLagMonitor(0, time.Second)
+	d.lock.RLock()
+	assert.Equal(defaultCheckInterval, d.lagMonitor.interval)
+	d.lock.RUnlock()
+	d.stopLagMonitor()
+}
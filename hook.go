@@ -0,0 +1,62 @@
+package reiner
+
+import "sync"
+
+// txHooks 是單層交易在提交或回溯後所要執行的函式。
+type txHooks struct {
+	commit   []func()
+	rollback []func()
+	// parent 是外層交易的函式，巢狀交易釋放儲存點時會將自己的函式交給外層交易，等到最外層的交易結束時才執行。
+	parent *txHooks
+	lock   sync.Mutex
+}
+
+// add 會依照種類加入一個在交易結束後執行的函式。
+func (h *txHooks) add(commit bool, fn func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if commit {
+		h.commit = append(h.commit, fn)
+	} else {
+		h.rollback = append(h.rollback, fn)
+	}
+}
+
+// take 會取出並清空所有的函式，這確保了每個函式最多只會執行一次。
+func (h *txHooks) take() (commit, rollback []func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	commit, rollback = h.commit, h.rollback
+	h.commit, h.rollback = nil, nil
+	return
+}
+
+// release 會在巢狀交易釋放儲存點後將這層交易的函式交給外層交易。
+func (h *txHooks) release() {
+	commit, rollback := h.take()
+	h.parent.lock.Lock()
+	defer h.parent.lock.Unlock()
+	h.parent.commit = append(h.parent.commit, commit...)
+	h.parent.rollback = append(h.parent.rollback, rollback...)
+}
+
+// run 會在交易成功提交（`committed` 為 `true`）或回溯之後依照加入的順序執行對應的函式，另一種函式則會被捨棄。
+func (h *txHooks) run(committed bool) {
+	commit, rollback := h.take()
+	hooks := rollback
+	if committed {
+		hooks = commit
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// addHook 會在目前的交易中加入一個在提交（`commit` 為 `true`）或回溯後執行的函式，不在交易中時會回傳 `ErrUnbegunTransaction`。
+func (d *DB) addHook(commit bool, fn func()) error {
+	if d.master.tx == nil || d.hooks == nil {
+		return ErrUnbegunTransaction
+	}
+	d.hooks.add(commit, fn)
+	return nil
+}
@@ -0,0 +1,122 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// lagMonitor 會定期讀取每個 Slave 資料庫的複寫延遲。
+type lagMonitor struct {
+	interval time.Duration
+	// threshold 是 Slave 能夠落後主要資料庫的最長時間，超過的 Slave 不會被用來讀取資料。
+	threshold time.Duration
+	stop      chan struct{}
+}
+
+// markLag 會依照量測到的複寫延遲更新連線的延遲狀態，`known` 為 `false` 表示無法得知延遲。
+func (c *connection) markLag(lag time.Duration, known bool, threshold time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lag = lag
+	c.lagging = !known || lag > threshold
+}
+
+// startLagMonitor 會在背景開始定期讀取所有 Slave 連線的複寫延遲，
+// 如果已經有正在執行的複寫延遲監控則會先停止它。
+func (d *DB) startLagMonitor(interval time.Duration, threshold time.Duration) {
+	d.stopLagMonitor()
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	m := &lagMonitor{
+		interval:  interval,
+		threshold: threshold,
+		stop:      make(chan struct{}),
+	}
+	d.lock.Lock()
+	d.lagMonitor = m
+	d.lock.Unlock()
+
+	go every(m.interval, m.stop, func() {
+		d.checkLag(m)
+	})
+}
+
+// stopLagMonitor 會停止正在背景執行的複寫延遲監控，並且讓所有 Slave 重新回到讀取輪詢中。
+func (d *DB) stopLagMonitor() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.lagMonitor == nil {
+		return
+	}
+	close(d.lagMonitor.stop)
+	d.lagMonitor = nil
+	for _, v := range d.slaves {
+		v.markLag(0, true, 0)
+	}
+}
+
+// checkLag 會讀取每個 Slave 連線的複寫延遲並且更新它們的延遲狀態。
+// 無法連線的 Slave 會保留上一次的狀態，因為這已經交由健康檢查處理。
+func (d *DB) checkLag(m *lagMonitor) {
+	for _, v := range d.getSlaves() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+		lag, known, err := secondsBehindMaster(ctx, v.db)
+		cancel()
+		if err != nil {
+			continue
+		}
+		v.markLag(lag, known, m.threshold)
+	}
+}
+
+// secondsBehindMaster 會透過 `SHOW REPLICA STATUS` 讀取 Slave 落後主要資料庫的時間，
+// 較舊的 MySQL 版本則會改用 `SHOW SLAVE STATUS`。
+// 當資料庫不是 Slave 或者複寫已經停止時，`known` 會是 `false`。
+func secondsBehindMaster(ctx context.Context, db *sql.DB) (lag time.Duration, known bool, err error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	if !rows.Next() {
+		err = rows.Err()
+		return
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return
+	}
+	for i, v := range columns {
+		if v != "Seconds_Behind_Master" && v != "Seconds_Behind_Source" {
+			continue
+		}
+		// 複寫停止時這個欄位會是 `NULL`。
+		if values[i] == nil {
+			return
+		}
+		var seconds int64
+		seconds, err = strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return
+		}
+		lag = time.Duration(seconds) * time.Second
+		known = true
+		return
+	}
+	return
+}
@@ -0,0 +1,243 @@
+package reiner
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 是 `NewMetrics` 預設的執行時間分佈區間（單位：秒）。
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// QueryObservation 是單次執行 SQL 指令的結果，重試時每次的執行都會各自被回報。
+type QueryObservation struct {
+	// Connection 是執行這個指令的連線名稱，主要資料庫會是 `master`，Slave 則會依照順序是 `slave_0`、`slave_1`⋯⋯。
+	// 在選擇連線之前就失敗的指令（例如：`ErrReadOnly`）會是 `none`。
+	Connection string
+	// Statement 是小寫的指令種類，例如：`select`、`insert`、`update`、`delete`、`replace`，其餘的指令會是 `other`。
+	Statement string
+	// Duration 是這次執行所花費的時間。
+	Duration time.Duration
+	// Error 是這次執行所發生的錯誤。
+	Error error
+}
+
+// MetricsSink 會接收每次執行 SQL 指令的結果，能夠用來將指標傳送到自訂的監控系統上。
+// 這會在執行指令的 Goroutine 中被呼叫，所以實作必須是併發安全的，而且不應該阻塞。
+type MetricsSink interface {
+	Observe(QueryObservation)
+}
+
+// Metrics 是內建的 `MetricsSink`，它會以連線與指令種類統計執行次數、錯誤次數與執行時間的分佈，
+// 並能夠透過 `Handler` 以 Prometheus 的文字格式輸出。
+type Metrics struct {
+	buckets []float64
+	queries map[queryKey]*queryMetric
+	lock    sync.Mutex
+}
+
+// queryKey 是統計指令時所使用的標籤。
+type queryKey struct {
+	connection string
+	statement  string
+}
+
+// queryMetric 是單組標籤的指令統計。
+type queryMetric struct {
+	count  uint64
+	errors uint64
+	// sum 是所有執行時間的總和（單位：秒）。
+	sum float64
+	// buckets 是執行時間小於或等於每個區間上限的次數。
+	buckets []uint64
+}
+
+// NewMetrics 會建立一個新的指標統計，傳入的參數為執行時間分佈區間的上限（單位：秒），沒有傳入時會使用 `DefaultLatencyBuckets`。
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{buckets: buckets, queries: make(map[queryKey]*queryMetric)}
+}
+
+// Observe 會將單次執行 SQL 指令的結果加入統計中。
+func (m *Metrics) Observe(o QueryObservation) {
+	key := queryKey{connection: o.Connection, statement: o.Statement}
+	seconds := o.Duration.Seconds()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	q, ok := m.queries[key]
+	if !ok {
+		q = &queryMetric{buckets: make([]uint64, len(m.buckets))}
+		m.queries[key] = q
+	}
+	q.count++
+	if o.Error != nil {
+		q.errors++
+	}
+	q.sum += seconds
+	for k, v := range m.buckets {
+		if seconds <= v {
+			q.buckets[k]++
+		}
+	}
+}
+
+// Handler 會回傳一個以 Prometheus 文字格式輸出指令統計與所有連線池狀態（`sql.DBStats`）的 HTTP 處理函式。
+func (m *Metrics) Handler(b *Builder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w, b.db.status())
+	})
+}
+
+// write 會以 Prometheus 文字格式輸出指令統計與連線池狀態。
+func (m *Metrics) write(w io.Writer, statuses []ConnectionStatus) {
+	m.lock.Lock()
+	keys := make([]queryKey, 0, len(m.queries))
+	queries := make(map[queryKey]queryMetric, len(m.queries))
+	for k, v := range m.queries {
+		keys = append(keys, k)
+		q := *v
+		q.buckets = append([]uint64{}, v.buckets...)
+		queries[k] = q
+	}
+	m.lock.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].connection != keys[j].connection {
+			return keys[i].connection < keys[j].connection
+		}
+		return keys[i].statement < keys[j].statement
+	})
+
+	header(w, "reiner_queries_total", "counter", "Total number of executed queries.")
+	for _, k := range keys {
+		fmt.Fprintf(w, "reiner_queries_total{%s} %d\n", k.labels(), queries[k].count)
+	}
+	header(w, "reiner_query_errors_total", "counter", "Total number of failed queries.")
+	for _, k := range keys {
+		fmt.Fprintf(w, "reiner_query_errors_total{%s} %d\n", k.labels(), queries[k].errors)
+	}
+	header(w, "reiner_query_duration_seconds", "histogram", "Query latency in seconds.")
+	for _, k := range keys {
+		q := queries[k]
+		for i, v := range m.buckets {
+			fmt.Fprintf(w, "reiner_query_duration_seconds_bucket{%s,le=\"%s\"} %d\n", k.labels(), formatFloat(v), q.buckets[i])
+		}
+		fmt.Fprintf(w, "reiner_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), q.count)
+		fmt.Fprintf(w, "reiner_query_duration_seconds_sum{%s} %s\n", k.labels(), formatFloat(q.sum))
+		fmt.Fprintf(w, "reiner_query_duration_seconds_count{%s} %d\n", k.labels(), q.count)
+	}
+
+	pools := []struct {
+		name, typ, help string
+		value           func(ConnectionStatus) float64
+	}{
+		{"reiner_pool_max_open_connections", "gauge", "Maximum number of open connections to the database.", func(s ConnectionStatus) float64 { return float64(s.Pool.MaxOpenConnections) }},
+		{"reiner_pool_open_connections", "gauge", "The number of established connections both in use and idle.", func(s ConnectionStatus) float64 { return float64(s.Pool.OpenConnections) }},
+		{"reiner_pool_in_use_connections", "gauge", "The number of connections currently in use.", func(s ConnectionStatus) float64 { return float64(s.Pool.InUse) }},
+		{"reiner_pool_idle_connections", "gauge", "The number of idle connections.", func(s ConnectionStatus) float64 { return float64(s.Pool.Idle) }},
+		{"reiner_pool_wait_count_total", "counter", "The total number of connections waited for.", func(s ConnectionStatus) float64 { return float64(s.Pool.WaitCount) }},
+		{"reiner_pool_wait_duration_seconds_total", "counter", "The total time blocked waiting for a new connection.", func(s ConnectionStatus) float64 { return s.Pool.WaitDuration.Seconds() }},
+		{"reiner_pool_max_idle_closed_total", "counter", "The total number of connections closed due to SetMaxIdleConns.", func(s ConnectionStatus) float64 { return float64(s.Pool.MaxIdleClosed) }},
+		{"reiner_pool_max_idle_time_closed_total", "counter", "The total number of connections closed due to SetConnMaxIdleTime.", func(s ConnectionStatus) float64 { return float64(s.Pool.MaxIdleTimeClosed) }},
+		{"reiner_pool_max_lifetime_closed_total", "counter", "The total number of connections closed due to SetConnMaxLifetime.", func(s ConnectionStatus) float64 { return float64(s.Pool.MaxLifetimeClosed) }},
+	}
+	for _, p := range pools {
+		header(w, p.name, p.typ, p.help)
+		for _, s := range statuses {
+			fmt.Fprintf(w, "%s{connection=\"%s\"} %s\n", p.name, s.Name, formatFloat(p.value(s)))
+		}
+	}
+}
+
+// labels 會回傳 Prometheus 格式的標籤。
+func (k queryKey) labels() string {
+	return fmt.Sprintf("connection=\"%s\",statement=\"%s\"", k.connection, k.statement)
+}
+
+// header 會輸出 Prometheus 指標的說明與種類。
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// formatFloat 會以 Prometheus 能夠解析的最短格式輸出浮點數。
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// statementType 會回傳 SQL 指令的小寫種類，`WITH` 指令會依照其主要的指令種類回傳。
+func statementType(query string) string {
+	tokens := tokenize(query)
+	i := 0
+	for i < len(tokens) && tokens[i].text == "(" {
+		i++
+	}
+	if i == len(tokens) {
+		return "other"
+	}
+	verb := tokens[i].word()
+	if verb == "WITH" {
+		verb = mainVerb(tokens[i+1:], tokens[i].depth)
+	}
+	switch verb {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return strings.ToLower(verb)
+	}
+	return "other"
+}
+
+// setMetricsSink 會替換接收指令執行結果的指標接收器，傳入 nil 表示停用。
+func (d *DB) setMetricsSink(sink MetricsSink) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.metrics = sink
+}
+
+// observe 會將單次執行 SQL 指令的結果回報給指標接收器，`conn` 是執行這個指令的連線，在選擇連線之前就失敗時會是 nil。
+func (d *DB) observe(conn *connection, query string, duration time.Duration, err error) {
+	d.lock.RLock()
+	sink := d.metrics
+	d.lock.RUnlock()
+	if sink == nil {
+		return
+	}
+	sink.Observe(QueryObservation{
+		Connection: d.connectionName(conn),
+		Statement:  statementType(query),
+		Duration:   duration,
+		Error:      err,
+	})
+}
+
+// connectionName 會回傳連線在指標中的名稱，交易中的連線副本會以 DSN 找到對應的連線，
+// 已經在重新載入時被移除的連線則會是 `retired`。
+func (d *DB) connectionName(conn *connection) string {
+	if conn == nil {
+		return "none"
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if conn.dataSourceName == d.master.dataSourceName {
+		return "master"
+	}
+	for k, v := range d.slaves {
+		if conn.dataSourceName == v.dataSourceName {
+			return slaveName(k)
+		}
+	}
+	return "retired"
+}
+
+// slaveName 會回傳第 `index` 個 Slave 連線在指標與狀態資訊中的名稱。
+func slaveName(index int) string {
+	return "slave_" + strconv.Itoa(index)
+}
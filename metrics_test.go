@@ -0,0 +1,50 @@
+package reiner
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	metrics := NewMetrics(0.5, 1)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master", Slaves: []string{"slave"}, Metrics: metrics})
+	assert.NoError(err)
+
+	_, err = b.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.NoError(err)
+	_, err = b.Table("Users").Get()
+	assert.Error(err)
+
+	w := httptest.NewRecorder()
+	metrics.Handler(b).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(body, `reiner_queries_total{connection="master",statement="update"} 1`)
+	assert.Contains(body, `reiner_query_errors_total{connection="master",statement="update"} 0`)
+	assert.Contains(body, `reiner_query_errors_total{connection="slave_0",statement="select"} 1`)
+	assert.Contains(body, `reiner_query_duration_seconds_bucket{connection="slave_0",statement="select",le="0.5"} 1`)
+	assert.Contains(body, `reiner_query_duration_seconds_bucket{connection="slave_0",statement="select",le="+Inf"} 1`)
+	assert.Contains(body, `reiner_query_duration_seconds_count{connection="master",statement="update"} 1`)
+	assert.Contains(body, `# TYPE reiner_pool_open_connections gauge`)
+	assert.Contains(body, `reiner_pool_open_connections{connection="slave_0"} `)
+
+	// 停用之後就不會再被統計。
+	b.SetMetricsSink(nil)
+	_, err = b.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.NoError(err)
+	w = httptest.NewRecorder()
+	metrics.Handler(b).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(w.Body.String(), `reiner_queries_total{connection="master",statement="update"} 1`)
+}
+
+func TestMetricsStatementType(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("select", statementType("select * from Users"))
+	assert.Equal("select", statementType("(SELECT 1) UNION (SELECT 2)"))
+	assert.Equal("delete", statementType("WITH Old AS (SELECT ID FROM Users) DELETE FROM Users"))
+	assert.Equal("insert", statementType("/* 註解 */ INSERT INTO Users VALUES (?)"))
+	assert.Equal("other", statementType("SHOW TABLES"))
+	assert.Equal("other", statementType(""))
+}
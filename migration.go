@@ -1,6 +1,7 @@
 package reiner
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -188,6 +189,10 @@ type Migration struct {
 	connection *DB
 	table      table
 	columns    []column
+	// statements 是需要在資料表格建立之後另外執行的 SQL 指令（例如：`CREATE INDEX`）。
+	statements []string
+	// ctx 是執行 SQL 指令時所使用的上下文。
+	ctx context.Context
 
 	// LasyQuery 是最後一次所執行的 SQL 指令。
 	LastQuery string
@@ -233,7 +238,21 @@ type key struct {
 
 // newMigration 會基於傳入的資料庫連線來建立一個新的資料表格遷移系統。
 func newMigration(db *DB) *Migration {
-	return &Migration{connection: db}
+	return &Migration{connection: db, ctx: context.Background()}
+}
+
+// dialect 會回傳建置 SQL 指令時所使用的方言，沒有資料庫連線時會使用 MySQL 方言。
+func (m *Migration) dialect() Dialect {
+	if m.connection == nil {
+		return MySQL{}
+	}
+	return m.connection.dialect
+}
+
+// WithContext 會指定執行 SQL 指令時所使用的上下文，這能夠在上下文被取消或逾時的時候中止正在執行的指令。
+func (m *Migration) WithContext(ctx context.Context) *Migration {
+	m.ctx = ctx
+	return m
 }
 
 // TinyInt 會將最後一個欲建立的欄位資料型態設置為 `tinyint`。
@@ -604,15 +623,22 @@ func (m *Migration) Table(tableName string, comment ...string) *Migration {
 }
 
 // Create 會執行先前的所有設置並且建立出一個相對應資料表格與其中的所有欄位。
+// 不支援在資料表格中定義索引的資料庫會在資料表格建立後另外透過 `CREATE INDEX` 建立索引。
 func (m *Migration) Create() (err error) {
 	// 建置出主要的 SQL 執行指令。
 	query := m.tableBuilder()
-	// 執行指令來建立相關的資料表格與欄位。
-	_, err = m.connection.exec(query)
-	// 保存最後一次所執行的 SQL 指令。
-	m.LastQuery = query
+	statements := m.statements
 	// 清除資料、欄位來重新開始一個資料表格遷移系統。
 	m.clean()
+	for _, v := range append([]string{query}, statements...) {
+		// 保存最後一次所執行的 SQL 指令。
+		m.LastQuery = v
+		// 執行指令來建立相關的資料表格、欄位與索引。
+		_, err = m.connection.exec(m.ctx, v)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -646,7 +672,7 @@ func (m *Migration) tableBuilder() (query string) {
 	uniqueQuery := m.indexBuilder("UNIQUE KEY")
 	indexQuery := m.indexBuilder("INDEX")
 	// 主要的開頭 SQL 執行指令。
-	query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ", m.table.name)
+	query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ", m.dialect().Quote(m.table.name))
 
 	// 湊合欄位和主鍵、索引的 SQL 執行指令。
 	if columnQuery != "" {
@@ -668,26 +694,9 @@ func (m *Migration) tableBuilder() (query string) {
 		query += fmt.Sprintf("(%s) ", trim(contentQuery))
 	}
 
-	// 引擎種類。
-	engineType := m.table.engineType
-	if engineType == "" {
-		engineType = EngineInnoDB
-	}
-	query += fmt.Sprintf("ENGINE=%s, ", strings.ToUpper(string(engineType)))
-	// 字符集。
-	if m.table.charset != "" {
-		query += fmt.Sprintf("DEFAULT CHARSET=%s, ", m.table.charset)
-	}
-	// 校對字符格式。
-	if m.table.collation != "" {
-		query += fmt.Sprintf("COLLATE=%s, ", m.table.collation)
-	}
-	// 備註。
-	if m.table.comment != "" {
-		query += fmt.Sprintf("COMMENT='%s', ", m.table.comment)
-	}
-	// 移除結尾多餘的逗號與空白。
-	query = trim(query)
+	// 引擎種類、字符集、校對字符格式與備註。
+	query += m.dialect().TableOptions(m.table.engineType, m.table.charset, m.table.collation, m.table.comment)
+	query = strings.TrimSpace(query)
 	return
 }
 
@@ -701,6 +710,7 @@ func (m *Migration) clean() {
 	m.table.uniqueKeys = []key{}
 	m.table.name = ""
 	m.columns = []column{}
+	m.statements = []string{}
 }
 
 // indexBuilder 會建置移除資料表格的 SQL 執行指令。。
@@ -708,11 +718,11 @@ func (m *Migration) dropBuilder(check bool, tableNames ...string) error {
 	// 遍歷資料表名稱切片來移除指定的資料表格。
 	for _, name := range tableNames {
 		// 建立 SQL 執行指令來準備移除指定資料表格。
-		query := fmt.Sprintf("DROP TABLE `%s`", name)
+		query := fmt.Sprintf("DROP TABLE %s", m.dialect().Quote(name))
 		if check {
-			query = fmt.Sprintf("DROP TABLE IF EXISTS `%s`", name)
+			query = fmt.Sprintf("DROP TABLE IF EXISTS %s", m.dialect().Quote(name))
 		}
-		_, err := m.connection.exec(query)
+		_, err := m.connection.exec(m.ctx, query)
 		// 保存最後一次執行的 SQL 指令。
 		m.LastQuery = query
 		// 清除資料、欄位來重新開始一個資料表格遷移系統。
@@ -742,9 +752,8 @@ func (m *Migration) indexBuilder(indexName string) (query string) {
 
 	// 每個索引群組。
 	for _, v := range keys {
-		// 建立欄位執行指令（`column_1`, `column_2`）。
-		columns := fmt.Sprintf("`%s`", strings.Join(v.columns, "`,`"))
 		// 替外鍵的目標欄位建立相關的 SQL 執行指令。
+		var reference string
 		if len(v.targetColumns) != 0 {
 			targetColumns = ""
 			for _, c := range v.targetColumns {
@@ -752,32 +761,26 @@ func (m *Migration) indexBuilder(indexName string) (query string) {
 				splitedStr := strings.Split(c, ".")
 				targetTable = splitedStr[0]
 				// 將取得到的欄位名稱追加到 SQL 執行指令中。
-				targetColumns += fmt.Sprintf("`%s`, ", splitedStr[1])
+				targetColumns += fmt.Sprintf("%s, ", m.dialect().Quote(splitedStr[1]))
 			}
 			// 移除結尾多餘的逗號與空白。
 			targetColumns = trim(targetColumns)
+			reference = fmt.Sprintf(" REFERENCES %s (%s)", m.dialect().Quote(targetTable), targetColumns)
 		}
 		// 外鍵更新、移除時的相關動作執行指令。
-		var onUpdate, onDelete string
 		if v.onUpdate != "" {
-			onUpdate = fmt.Sprintf(" ON UPDATE %s", v.onUpdate)
+			reference += fmt.Sprintf(" ON UPDATE %s", v.onUpdate)
 		}
 		if v.onDelete != "" {
-			onDelete = fmt.Sprintf(" ON DELETE %s", v.onDelete)
+			reference += fmt.Sprintf(" ON DELETE %s", v.onDelete)
 		}
-		// 沒有群組名稱的索引。
-		if v.name == "" && len(v.targetColumns) == 0 {
-			query += fmt.Sprintf("%s (%s), ", indexName, columns)
-			// 命名索引。
-		} else if v.name != "" && len(v.targetColumns) == 0 {
-			query += fmt.Sprintf("%s `%s` (%s), ", indexName, v.name, columns)
-			// 沒有群組名稱的外鍵。
-		} else if v.name == "" && len(v.targetColumns) != 0 {
-			query += fmt.Sprintf("%s (%s) REFERENCES `%s` (%s)%s%s, ", indexName, columns, targetTable, targetColumns, onUpdate, onDelete)
-			// 多個外鍵。
-		} else if v.name != "" && len(v.targetColumns) != 0 {
-			query += fmt.Sprintf("%s %s (%s) REFERENCES `%s` (%s)%s%s, ", indexName, v.name, columns, targetTable, targetColumns, onUpdate, onDelete)
+		// 無法定義在資料表格中的索引會在資料表格建立之後另外執行。
+		definition, statement := m.dialect().Key(m.table.name, indexName, v.name, v.columns, reference)
+		if statement != "" {
+			m.statements = append(m.statements, statement)
+			continue
 		}
+		query += fmt.Sprintf("%s, ", definition)
 	}
 	// 移除結尾多餘的逗號與空白。
 	query = trim(query)
@@ -788,21 +791,21 @@ func (m *Migration) indexBuilder(indexName string) (query string) {
 func (m *Migration) columnBuilder() (query string) {
 	for _, v := range m.columns {
 		// 欄位名稱。
-		query += fmt.Sprintf("`%s` ", v.name)
+		query += fmt.Sprintf("%s ", m.dialect().Quote(v.name))
 
-		// 資料型態。
-		dataType := strings.ToUpper(v.dataType)
+		// 資料型態的參數。
+		var args string
 		switch t := v.length.(type) {
 		// VARCHAR(30)
 		case int:
-			query += fmt.Sprintf("%s(%d) ", dataType, t)
+			args = fmt.Sprintf("%d", t)
 		// ENUM(1, 2, 3, 4)
 		case []int:
 			// 將 `[]int`` 轉換成字串並且透過逗點分隔。
-			query += fmt.Sprintf("%s(%s) ", dataType, strings.Trim(strings.Join(strings.Split(fmt.Sprint(t), " "), ", "), "[]"))
+			args = strings.Trim(strings.Join(strings.Split(fmt.Sprint(t), " "), ", "), "[]")
 		// ENUM("A", "B", "C")
 		case []string:
-			query += fmt.Sprintf("%s(%s) ", dataType, strings.Join(t, ", "))
+			args = strings.Join(t, ", ")
 		// FLOAT(1, 2) or ENUM(1, 2, "A", "B")
 		case []interface{}:
 			// 將選項從長度資料中展開。
@@ -816,23 +819,18 @@ func (m *Migration) columnBuilder() (query string) {
 				}
 			}
 			// 移除結尾多餘的逗號與空白。
-			query += fmt.Sprintf("%s(%s) ", dataType, trim(options))
-		// DATETIME
-		case nil:
-			query += fmt.Sprintf("%s ", dataType)
+			args = trim(options)
 		}
+		// 資料型態，這同時也包含了非負數的設置。
+		query += fmt.Sprintf("%s ", m.dialect().DataType(v.dataType, args, v.unsigned, v.autoIncrement))
 
-		// 非負數。
-		if v.unsigned {
-			query += "UNSIGNED "
-		}
 		// 允許空值。
 		if !v.nullable {
 			query += "NOT NULL "
 		}
 		// 自動遞增。
-		if v.autoIncrement {
-			query += "AUTO_INCREMENT "
+		if v.autoIncrement && m.dialect().AutoIncrement() != "" {
+			query += fmt.Sprintf("%s ", m.dialect().AutoIncrement())
 		}
 		// 預設值。
 		switch t := v.defaultValue.(type) {
@@ -862,7 +860,7 @@ func (m *Migration) columnBuilder() (query string) {
 		}
 		// 備註。
 		if v.comment != "" {
-			query += fmt.Sprintf("COMMENT '%s'", v.comment)
+			query += m.dialect().Comment(v.comment)
 		}
 		// 最終結尾。
 		query += ", "
@@ -1,5 +1,7 @@
 package reiner
 
+import "sort"
+
 // New 會建立一個新的連線並且提供 MySQL 的 SQL 指令建置系統。當沒有傳入參數時，
 // 會變成 SQL 指令建置模式，而這個情況下你可以透過 Reiner 來建立 SQL 查詢指令。
 //     .New()
@@ -8,31 +10,58 @@ package reiner
 // 若需要讀／寫區分，第一個參數則是為主要資料庫的 DSN，而其餘的則是 Slave 資料庫的 DSN。
 //     .New("root:root@/master", root:root@/slave")
 //     .New("root:root@/master", []string{"root:root@/slave", "root:root@/slave2"})
+// 如果 Slave 的硬體規格不同，可以傳入以 DSN 為鍵的權重，這會以加權輪詢的方式分配讀取。
+//     .New("root:root@/master", map[string]int{"root:root@/slave": 3, "root:root@/slave2": 1})
 // 查看 https://dev.mysql.com/doc/refman/5.7/en/replication-solutions-scaleout.html 了解更多資訊。
 func New(dataSourceNames ...interface{}) (*Builder, error) {
-	var slaves []string
-	var master string
+	config := Config{PingOnStartup: true}
 
 	switch len(dataSourceNames) {
 	// SQL 指令建置模式。
 	case 0:
-		return &Builder{executable: false, Timestamp: &Timestamp{}, PageLimit: 20}, nil
 	// 單個主要資料庫連線。
 	case 1:
-		master = dataSourceNames[0].(string)
+		config.Master = dataSourceNames[0].(string)
 	// 主從資料庫。
 	case 2:
-		master = dataSourceNames[0].(string)
+		config.Master = dataSourceNames[0].(string)
 		switch v := dataSourceNames[1].(type) {
 		// 多個 Slaves。
 		case []string:
-			slaves = v
+			config.Slaves = v
 		// 單個 Slave。
 		case string:
-			slaves = append(slaves, v)
+			config.Slaves = append(config.Slaves, v)
+		// 帶有權重的多個 Slaves。
+		case map[string]int:
+			config.Weights = v
+			for dataSourceName := range v {
+				config.Slaves = append(config.Slaves, dataSourceName)
+			}
+			// 依照名稱排序來確保 Slave 的順序不會因為 `map` 而每次都不同。
+			sort.Strings(config.Slaves)
 		}
 	}
-	d, err := newDatabase(master, slaves)
+	return NewWithConfig(config)
+}
+
+// NewWithConfig 和 `New` 相同，但能夠透過設置來調整主要資料庫與 Slave 資料庫各自的連線池，
+// 還有啟動時是否要 ping 資料庫與其最長等待時間。沒有指定 `Master` 時會變成 SQL 指令建置模式。
+// 透過 `Dialect` 能夠改為建置 SQLite 或 PostgreSQL 的 SQL 指令（預設為：`MySQL`）。
+//     .NewWithConfig(reiner.Config{
+//         Master:        "root:root@/master",
+//         Slaves:        []string{"root:root@/slave"},
+//         MasterPool:    reiner.PoolConfig{MaxOpenConns: 50, ConnMaxLifetime: time.Hour},
+//         PingOnStartup: true,
+//     })
+func NewWithConfig(config Config) (*Builder, error) {
+	if config.Dialect == nil {
+		config.Dialect = MySQL{}
+	}
+	if config.Master == "" {
+		return &Builder{executable: false, dialect: config.Dialect, Timestamp: &Timestamp{}, PageLimit: 20}, nil
+	}
+	d, err := newDatabase(config)
 	if err != nil {
 		return &Builder{}, err
 	}
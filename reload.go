@@ -0,0 +1,92 @@
+package reiner
+
+import (
+	"time"
+)
+
+// drainInterval 是舊的連線池在關閉前檢查是否還有執行中指令的間隔。
+const drainInterval = 10 * time.Millisecond
+
+// reload 會以新的 DSN 替換主要資料庫與 Slave 資料庫的連線池，新的連線池都必須能夠在 `ConnectTimeout` 內 ping 成功，
+// 只要其中一個失敗就不會替換任何的連線池。DSN 沒有變更的連線池會被保留，這能夠用來在執行期間新增或移除 Slave。
+func (d *DB) reload(master string, slaves []string) error {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+	d.lock.RLock()
+	config := d.config
+	keep := map[string]*connection{d.master.dataSourceName: d.master}
+	for _, v := range d.slaves {
+		keep[v.dataSourceName] = v
+	}
+	d.lock.RUnlock()
+	config.Master = master
+	config.Slaves = slaves
+	config.PingOnStartup = true
+	return d.replaceConnections(config, keep)
+}
+
+// replaceConnections 會依照設置開啟新的連線池並替換目前的連線，`keep` 中以 DSN 為鍵的連線則會被沿用而不重新開啟。
+// 開啟失敗時會關閉所有剛開啟的連線池並且回傳錯誤，替換成功後沒有被沿用的舊連線池則會在背景中排空並關閉。
+// 呼叫者必須在取得 `keep` 之前就持有 `reloadLock`，否則其他的替換可能會關閉被沿用的連線池。
+func (d *DB) replaceConnections(config Config, keep map[string]*connection) error {
+	var opened []*connection
+	open := func(dataSourceName string, pool PoolConfig) (*connection, error) {
+		if c, ok := keep[dataSourceName]; ok {
+			return c, nil
+		}
+		db, err := openDatabase(dataSourceName, pool, config)
+		if err != nil {
+			if db != nil {
+				db.Close()
+			}
+			return nil, err
+		}
+		c := newConnection(db, dataSourceName, config)
+		opened = append(opened, c)
+		return c, nil
+	}
+	var errs []error
+	master, err := open(config.Master, config.MasterPool)
+	errs = append(errs, err)
+	var slaves []*connection
+	for _, v := range config.Slaves {
+		slave, err := open(v, config.SlavePool)
+		errs = append(errs, err)
+		slaves = append(slaves, slave)
+	}
+	if err := joinErrors(errs...); err != nil {
+		for _, v := range opened {
+			v.db.Close()
+		}
+		return err
+	}
+
+	d.lock.Lock()
+	old := append([]*connection{d.master}, d.slaves...)
+	d.master = master
+	d.slaves = slaves
+	d.config.Master = config.Master
+	d.config.Slaves = config.Slaves
+	d.lock.Unlock()
+
+	used := map[*connection]bool{master: true}
+	for _, v := range slaves {
+		used[v] = true
+	}
+	for _, v := range old {
+		if !used[v] {
+			go v.retire()
+		}
+	}
+	return nil
+}
+
+// retire 會等待這個已經被替換的連線上所有執行中的指令與尚未結束的交易（包括 XA 交易）結束，
+// 接著清空已準備指令快取並關閉連線池，因為交易中的指令仍然會透過這個連線池準備。
+func (c *connection) retire() {
+	for c.pending() > 0 || c.openTransactions() > 0 {
+		time.Sleep(drainInterval)
+	}
+	c.stmts.clear()
+	c.db.Close()
+}
@@ -0,0 +1,132 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	sql.Register("reiner_test", toggleDriver{})
+}
+
+// toggleDriver 是一個假的資料庫驅動程式，DSN 中含有 `down` 的資料庫會無法連線，
+// 含有 `noid` 的資料庫則會像 PostgreSQL 的驅動程式一樣不支援 `LastInsertId`，含有 `slow` 的資料庫則需要 `slowOpen` 才能連線，
+// 含有 `rows` 的資料庫的查詢則會回傳 `toggleRows` 而不是失敗。
+type toggleDriver struct{}
+
+// slowOpen 是 DSN 中含有 `slow` 的資料庫連線所需要的時間。
+const slowOpen = 50 * time.Millisecond
+
+func (toggleDriver) Open(name string) (driver.Conn, error) {
+	if strings.Contains(name, "slow") {
+		time.Sleep(slowOpen)
+	}
+	if strings.Contains(name, "down") {
+		return nil, errors.New("connection refused")
+	}
+	return toggleConn{noInsertID: strings.Contains(name, "noid"), rows: strings.Contains(name, "rows")}, nil
+}
+
+// testDialect 是使用假資料庫驅動程式的 MySQL 方言。
+type testDialect struct {
+	MySQL
+}
+
+func (testDialect) DriverName() string {
+	return "reiner_test"
+}
+
+func TestReload(t *testing.T) {
+	assert := assert.New(t)
+	d, err := newDatabase(Config{Dialect: testDialect{}, Master: "master", Slaves: []string{"slave"}, PingOnStartup: true})
+	assert.NoError(err)
+	master, slave := d.master, d.slaves[0]
+
+	// 只要其中一個連線池無法連線，就不會替換任何的連線池。
+	assert.Error(d.reload("master2", []string{"slave", "slave-down"}))
+	assert.Equal(master, d.getMaster())
+	assert.Equal([]*connection{slave}, d.getSlaves())
+
+	assert.NoError(d.reload("master2", []string{"slave", "slave2"}))
+	assert.Equal("master2", d.getMaster().dataSourceName)
+	assert.Len(d.getSlaves(), 2)
+	assert.Equal(slave, d.getSlaves()[0])
+	assert.Equal("slave2", d.getSlaves()[1].dataSourceName)
+	assert.Eventually(func() bool {
+		return master.db.Ping() != nil
+	}, time.Second, drainInterval)
+	assert.NoError(slave.db.Ping())
+
+	// 移除所有的 Slave 之後，讀取會改由主要資料庫處理。
+	assert.NoError(d.reload("master2", nil))
+	assert.Equal(d.getMaster(), d.getDB("SELECT * FROM Users", false))
+}
+
+func TestReloadDrain(t *testing.T) {
+	assert := assert.New(t)
+	d, err := newDatabase(Config{Dialect: testDialect{}, Master: "master"})
+	assert.NoError(err)
+	master := d.master
+
+	stmt, err := d.prepare(context.Background(), "UPDATE Users SET Username = ?", false)
+	assert.NoError(err)
+	assert.NoError(d.reload("master2", nil))
+
+	// 舊的連線池會等到執行中的指令結束後才被關閉。
+	time.Sleep(drainInterval * 3)
+	_, err = stmt.Exec("YamiOdymel")
+	assert.NoError(err)
+	stmt.close()
+	assert.Eventually(func() bool {
+		return master.db.Ping() != nil
+	}, time.Second, drainInterval)
+}
+
+func TestReloadDrainTransaction(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master", StatementCacheSize: 10})
+	assert.NoError(err)
+	master := b.db.getMaster()
+
+	tx, err := b.Begin()
+	assert.NoError(err)
+	assert.NoError(b.Reload("master2", nil))
+
+	// 舊的連線池會等到交易結束後才被關閉，交易中的指令仍然會透過舊的連線池準備。
+	time.Sleep(drainInterval * 3)
+	assert.NoError(master.db.Ping())
+	_, err = tx.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.NoError(err)
+	assert.NoError(tx.Commit())
+	assert.Eventually(func() bool {
+		return master.db.Ping() != nil
+	}, time.Second, drainInterval)
+}
+
+func TestReloadConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	d, err := newDatabase(Config{Dialect: testDialect{}, Master: "master", Slaves: []string{"slave"}})
+	assert.NoError(err)
+
+	// 開啟新連線池的期間，其他的替換必須等待，否則被沿用的 Slave 可能已經被另一個替換關閉了。
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(d.reload("master-slow", []string{"slave"}))
+		close(done)
+	}()
+	time.Sleep(slowOpen / 5)
+	assert.NoError(d.reload("master", []string{"slave2"}))
+	<-done
+	time.Sleep(drainInterval * 3)
+	assert.NoError(d.getMaster().db.Ping())
+	for _, v := range d.getSlaves() {
+		assert.NoError(v.db.Ping())
+	}
+}
@@ -0,0 +1,95 @@
+package reiner
+
+import (
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RetryPolicy 是遇到暫時性錯誤時的重試策略，重試前的等待時間會以指數成長並且帶有隨機抖動。
+type RetryPolicy struct {
+	// MaxAttempts 是包括第一次在內最多會執行幾次，小於 `2` 表示不重試。
+	MaxAttempts int
+	// BaseDelay 是第一次重試前的等待時間，之後每次重試都會加倍。
+	BaseDelay time.Duration
+	// MaxDelay 是每次重試前最長的等待時間，零值表示不限制。
+	MaxDelay time.Duration
+	// Writes 表示是否也要重試寫入指令，因為這可能會導致資料被重複寫入，所以必須明確地啟用。
+	Writes bool
+}
+
+// delay 會回傳第 `attempt` 次執行失敗後，重試前所需要等待的時間。
+// 等待時間會介於指數成長後的一半到全部之間，以避免多個客戶端同時重試。
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// isTransient 會回傳錯誤是否為重試後可能就會成功的暫時性錯誤。
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		// 鎖定等待逾時、死結。
+		case 1205, 1213:
+			return true
+		}
+	}
+	return strings.Contains(err.Error(), "server has gone away")
+}
+
+// isSerializationFailure 會回傳錯誤是否為交易因為死結或序列化失敗而被資料庫中止，
+// 這樣的交易在重新開始後通常就能夠成功。PostgreSQL 的錯誤則會以 `SQLSTATE 40001` 判斷。
+func isSerializationFailure(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == "40001"
+	}
+	return false
+}
+
+// retry 會執行傳入的函式，並且在 `retryable` 為 `true` 且遇到暫時性錯誤時依照重試策略重新執行。
+// 每次執行都會各自被保存於蹤跡資訊中。交易中的指令不會被重試，因為交易在發生錯誤後可能已經無法使用。
+func (b *Builder) retry(retryable bool, fn func() error) (err error) {
+	attempts := 1
+	if retryable && !b.db.inTransaction() && b.retryPolicy.MaxAttempts > 1 {
+		attempts = b.retryPolicy.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		// 執行時間會同時被用在追蹤模式與指標上。
+		start := time.Now()
+		b.conn = nil
+		err = fn()
+		b.saveTrace(err, b.query, start, attempt)
+		if b.conn != nil {
+			b.conn.breaker.record(err)
+		}
+		b.db.observe(b.conn, b.query, time.Since(start), err)
+		if err == nil || attempt >= attempts || !isTransient(err) {
+			return
+		}
+		select {
+		case <-time.After(b.retryPolicy.delay(attempt)):
+		case <-b.context().Done():
+			return
+		}
+	}
+}
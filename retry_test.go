@@ -0,0 +1,73 @@
+package reiner
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransient(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isTransient(driver.ErrBadConn))
+	assert.True(isTransient(mysql.ErrInvalidConn))
+	assert.True(isTransient(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}))
+	assert.True(isTransient(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}))
+	assert.True(isTransient(errors.New("Error 2006: MySQL server has gone away")))
+	assert.False(isTransient(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	assert.False(isTransient(ErrNoTable))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert := assert.New(t)
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		d := p.delay(1)
+		assert.True(d >= 50*time.Millisecond && d <= 100*time.Millisecond)
+		d = p.delay(5)
+		assert.True(d >= 150*time.Millisecond && d <= 300*time.Millisecond)
+	}
+	assert.Equal(time.Duration(0), RetryPolicy{}.delay(3))
+}
+
+func TestRetryAttempts(t *testing.T) {
+	assert := assert.New(t)
+	b := newBuilder(newTestDatabase()).SetTrace(true).SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	b.query = "SELECT * FROM Users"
+
+	calls := 0
+	err := b.retry(true, func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	assert.Len(b.Traces, 3)
+	assert.Equal(driver.ErrBadConn, b.Traces[0].Error)
+	assert.Equal(3, b.Traces[2].Attempt)
+
+	calls = 0
+	err = b.retry(false, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.Equal(driver.ErrBadConn, err)
+	assert.Equal(1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = b.WithContext(ctx).retry(true, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+	assert.Equal(driver.ErrBadConn, err)
+	assert.Equal(1, calls)
+}
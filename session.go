@@ -0,0 +1,39 @@
+package reiner
+
+import (
+	"sync"
+	"time"
+)
+
+// session 是一段讀寫一致的工作階段，在寫入之後的一段時間內所有的讀取都會被導向主要資料庫。
+// 同個工作階段會被所有衍生的建置系統共用，所以必須能夠安全地在多個 Goroutine 中使用。
+type session struct {
+	window    time.Duration
+	lastWrite time.Time
+	lock      sync.RWMutex
+}
+
+// newSession 會建立一個在寫入後黏著主要資料庫 `window` 時間的工作階段。
+func newSession(window time.Duration) *session {
+	return &session{window: window}
+}
+
+// wrote 會在執行的指令不是讀取指令時記錄寫入的時間，傳入 nil 工作階段時不會有任何動作。
+func (s *session) wrote(query string) {
+	if s == nil || isRead(query) {
+		return
+	}
+	s.lock.Lock()
+	s.lastWrite = time.Now()
+	s.lock.Unlock()
+}
+
+// sticky 會回傳目前是否仍在寫入後的黏著時間內，傳入 nil 工作階段時永遠會是 `false`。
+func (s *session) sticky() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return !s.lastWrite.IsZero() && time.Since(s.lastWrite) < s.window
+}
@@ -0,0 +1,128 @@
+package reiner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// workTracker 會追蹤資料庫中正在執行的指令與尚未結束的交易，讓關閉資料庫時能夠等待它們完成。
+type workTracker struct {
+	// count 是目前正在執行的指令與交易數量。
+	count int
+	// closed 表示資料庫正在關閉，不再接受新的指令與交易。
+	closed bool
+	// idle 會在關閉後所有的工作都完成時被關閉。
+	idle chan struct{}
+	lock sync.Mutex
+}
+
+// newWorkTracker 會建立一個新的工作追蹤器。
+func newWorkTracker() *workTracker {
+	return &workTracker{idle: make(chan struct{})}
+}
+
+// add 會開始一項新的工作，並回傳一個結束這項工作的函式，重複呼叫該函式並不會有任何影響。
+// 當資料庫正在關閉時則會回傳 `ErrShutdown`。
+func (t *workTracker) add() (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, ErrShutdown
+	}
+	t.count++
+	var once sync.Once
+	return func() {
+		once.Do(t.done)
+	}, nil
+}
+
+// done 會結束一項工作，如果資料庫正在關閉且這是最後一項工作，則會通知正在等待的關閉程序。
+func (t *workTracker) done() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.count--
+	if t.closed && t.count == 0 {
+		close(t.idle)
+	}
+}
+
+// close 會停止接受新的工作，並回傳一個會在所有工作都完成時被關閉的通道。
+func (t *workTracker) close() <-chan struct{} {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.closed {
+		t.closed = true
+		if t.count == 0 {
+			close(t.idle)
+		}
+	}
+	return t.idle
+}
+
+// shutdown 會停止接受新的指令與交易，並且在上下文結束前等待正在執行的指令與尚未結束的交易完成，
+// 最後不論是否等待完畢都會關閉所有的連線池。回傳的錯誤會包含上下文的錯誤與所有關閉連線池時發生的錯誤。
+func (d *DB) shutdown(ctx context.Context) error {
+	var err error
+	if d.work != nil {
+		select {
+		case <-d.work.close():
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	return joinErrors(err, d.disconnect())
+}
+
+// multiError 是同時發生的多個錯誤，`errors.Is` 與 `errors.As` 會依序比對其中的每個錯誤。
+type multiError []error
+
+// joinErrors 會將多個錯誤合併成一個錯誤並略過其中的 nil，全部都是 nil 時會回傳 nil，只有一個錯誤時則會直接回傳該錯誤。
+func joinErrors(errs ...error) error {
+	var e multiError
+	for _, v := range errs {
+		if v != nil {
+			e = append(e, v)
+		}
+	}
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
+
+// Error 會以換行分隔每個錯誤的訊息。
+func (e multiError) Error() string {
+	messages := make([]string, len(e))
+	for k, v := range e {
+		messages[k] = v.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Is 會回傳其中是否有任何一個錯誤符合 `target`。
+func (e multiError) Is(target error) bool {
+	for _, v := range e {
+		if errors.Is(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As 會將第一個符合 `target` 型態的錯誤指派給 `target`。
+func (e multiError) As(target interface{}) bool {
+	for _, v := range e {
+		if errors.As(v, target) {
+			return true
+		}
+	}
+	return false
+}
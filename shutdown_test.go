@@ -0,0 +1,71 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownDrainsTransaction(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	d.master.db = sql.OpenDB(&toggleConnector{})
+	d.slaves[0].db = sql.OpenDB(&toggleConnector{})
+	b := newBuilder(d)
+
+	tx, err := b.Begin()
+	assert.NoError(err)
+
+	result := make(chan error)
+	go func() {
+		result <- b.Shutdown(context.Background())
+	}()
+	select {
+	case <-result:
+		assert.Fail("shutdown should wait for the open transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 已經開始的交易在等待期間仍然能夠執行指令。
+	_, err = tx.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.NoError(err)
+	_, err = b.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.Equal(ErrShutdown, err)
+	_, err = b.Begin()
+	assert.Equal(ErrShutdown, err)
+
+	assert.NoError(tx.Commit())
+	assert.NoError(<-result)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase("root:root@/slave")
+	d.master.db = sql.OpenDB(&toggleConnector{})
+	b := newBuilder(d)
+
+	_, err := b.Begin()
+	assert.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = b.Shutdown(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Error(d.master.db.Ping())
+}
+
+func TestJoinErrors(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(joinErrors(nil, nil))
+	assert.Equal(ErrShutdown, joinErrors(nil, ErrShutdown))
+
+	err := joinErrors(context.DeadlineExceeded, nil, ErrShutdown)
+	assert.Equal("context deadline exceeded\nreiner: the database is shutting down", err.Error())
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.True(errors.Is(err, ErrShutdown))
+	assert.False(errors.Is(err, ErrReadOnly))
+}
@@ -0,0 +1,181 @@
+package reiner
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache 是單個連線池中以 SQL 指令為鍵的已準備指令快取，超過容量時會移除最久沒有被使用的指令。
+type stmtCache struct {
+	size  int
+	items map[string]*list.Element
+	// order 是指令的使用順序，最前面的是最近被使用的指令。
+	order  *list.List
+	hits   uint64
+	misses uint64
+	lock   sync.Mutex
+}
+
+// cachedStmt 是單個被快取的已準備指令。
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+	// refs 是目前正在使用這個指令的數量，被移出快取的指令會等到沒有人使用時才關閉。
+	refs    int
+	evicted bool
+}
+
+// statement 是一個已準備好的 SQL 指令與執行它的連線。
+type statement struct {
+	*sql.Stmt
+	conn *connection
+	// cached 是這個指令所使用的快取，沒有啟用快取時會是 nil。
+	cached *cachedStmt
+	// done 會在指令用完時通知工作追蹤器。
+	done func()
+}
+
+// newStmtCache 會建立一個指定容量的已準備指令快取，容量小於 `1` 時會回傳 nil 表示停用快取。
+func newStmtCache(size int) *stmtCache {
+	if size < 1 {
+		return nil
+	}
+	return &stmtCache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// acquire 會從快取中取得指定 SQL 指令的已準備指令，沒有的話則會在資料庫上準備並放入快取。
+// 取得的指令在用完之後必須透過 `release` 歸還。
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*cachedStmt, error) {
+	c.lock.Lock()
+	if e, ok := c.items[query]; ok {
+		c.hits++
+		c.order.MoveToFront(e)
+		s := e.Value.(*cachedStmt)
+		s.refs++
+		c.lock.Unlock()
+		return s, nil
+	}
+	c.misses++
+	c.lock.Unlock()
+
+	// 準備指令需要和資料庫來回一趟，所以不在鎖定期間執行。
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// 其他的 Goroutine 可能已經在準備的期間放入了相同的指令。
+	if e, ok := c.items[query]; ok {
+		stmt.Close()
+		c.order.MoveToFront(e)
+		s := e.Value.(*cachedStmt)
+		s.refs++
+		return s, nil
+	}
+	s := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.order.PushFront(s)
+	for c.order.Len() > c.size {
+		c.evict(c.order.Back())
+	}
+	return s, nil
+}
+
+// release 會歸還用完的已準備指令，已經被移出快取且沒有人使用的指令會在這個時候被關閉。
+func (c *stmtCache) release(s *cachedStmt) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s.refs--
+	if s.evicted && s.refs == 0 {
+		s.stmt.Close()
+	}
+}
+
+// evict 會將指令移出快取，沒有人使用的指令會直接被關閉。
+func (c *stmtCache) evict(e *list.Element) {
+	s := c.order.Remove(e).(*cachedStmt)
+	delete(c.items, s.query)
+	s.evicted = true
+	if s.refs == 0 {
+		s.stmt.Close()
+	}
+}
+
+// clear 會清空快取並且關閉所有的已準備指令，這會在資料庫斷線或重新連線時被呼叫。
+func (c *stmtCache) clear() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for c.order.Len() > 0 {
+		c.evict(c.order.Back())
+	}
+}
+
+// stats 會回傳快取的命中與未命中次數。
+func (c *stmtCache) stats() (hits, misses uint64) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.hits, c.misses
+}
+
+// prepare 會在這個連線上準備 SQL 指令，有啟用快取時會優先使用已快取的指令。
+// 在交易中則會透過 `Tx.StmtContext` 將快取的指令綁定到交易上，XA 交易中的指令則不會被快取。
+func (c *connection) prepare(ctx context.Context, query string) (*statement, error) {
+	s := &statement{conn: c}
+	// XA 交易的連線只會使用一次，所以不需要快取。
+	if c.conn != nil {
+		var err error
+		if s.Stmt, err = c.conn.PrepareContext(ctx, query); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if c.stmts == nil {
+		var err error
+		if c.tx != nil {
+			s.Stmt, err = c.tx.PrepareContext(ctx, query)
+		} else {
+			s.Stmt, err = c.db.PrepareContext(ctx, query)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	cached, err := c.stmts.acquire(ctx, c.db, query)
+	if err != nil {
+		return nil, err
+	}
+	s.cached = cached
+	s.Stmt = cached.stmt
+	if c.tx != nil {
+		s.Stmt = c.tx.StmtContext(ctx, cached.stmt)
+	}
+	return s, nil
+}
+
+// close 會在指令用完之後釋放連線的執行中指令數量，並且歸還快取的指令。
+// 沒有被快取的指令與綁定到交易上的指令則會直接被關閉。
+func (s *statement) close() {
+	s.conn.release()
+	if s.done != nil {
+		s.done()
+	}
+	if s.cached == nil || s.Stmt != s.cached.stmt {
+		s.Stmt.Close()
+	}
+	if s.cached != nil {
+		s.conn.stmts.release(s.cached)
+	}
+}
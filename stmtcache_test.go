@@ -0,0 +1,75 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStmtCacheLRU(t *testing.T) {
+	assert := assert.New(t)
+	db := sql.OpenDB(&toggleConnector{})
+	c := newStmtCache(2)
+	ctx := context.Background()
+
+	for _, v := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3"} {
+		s, err := c.acquire(ctx, db, v)
+		assert.NoError(err)
+		c.release(s)
+	}
+	hits, misses := c.stats()
+	assert.Equal(uint64(1), hits)
+	assert.Equal(uint64(3), misses)
+	assert.Contains(c.items, "SELECT 1")
+	assert.Contains(c.items, "SELECT 3")
+	assert.NotContains(c.items, "SELECT 2")
+	assert.Nil(newStmtCache(0))
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	assert := assert.New(t)
+	db := sql.OpenDB(&toggleConnector{})
+	c := newStmtCache(1)
+	ctx := context.Background()
+
+	inUse, err := c.acquire(ctx, db, "UPDATE Users SET Username = ?")
+	assert.NoError(err)
+	other, err := c.acquire(ctx, db, "SELECT 1")
+	assert.NoError(err)
+	c.release(other)
+	assert.True(inUse.evicted)
+
+	// 被移出快取的指令在歸還之前仍然能夠使用。
+	_, err = inUse.stmt.Exec("YamiOdymel")
+	assert.NoError(err)
+	c.release(inUse)
+	_, err = inUse.stmt.Exec("YamiOdymel")
+	assert.Error(err)
+
+	c.clear()
+	assert.Empty(c.items)
+	_, err = other.stmt.Exec()
+	assert.Error(err)
+}
+
+func TestStmtCachePrepare(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDatabase()
+	d.master.db = sql.OpenDB(&toggleConnector{})
+	d.master.stmts = newStmtCache(10)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		stmt, err := d.prepare(ctx, "UPDATE Users SET Username = ?", false)
+		assert.NoError(err)
+		_, err = stmt.ExecContext(ctx, "YamiOdymel")
+		assert.NoError(err)
+		stmt.close()
+	}
+	status := d.status()[0]
+	assert.Equal(uint64(2), status.StatementCacheHits)
+	assert.Equal(uint64(1), status.StatementCacheMisses)
+	assert.Equal(int64(0), *d.master.inFlight)
+}
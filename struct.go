@@ -0,0 +1,242 @@
+package reiner
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField 是結構體中對應到資料表格欄位的欄位，欄位名稱與選項來自 `db` 標籤（例如：`db:"ID,autoincrement"`）。
+type structField struct {
+	column string
+	index  []int
+	// omitEmpty 表示欄位是零值時不會被寫入，讓資料庫使用預設值。
+	omitEmpty bool
+	// readOnly 表示欄位只會被讀取，永遠不會被插入或更新（例如：由資料庫產生的建立時間）。
+	readOnly bool
+	// autoIncrement 表示欄位是自動遞增的主鍵，零值時不會被插入，插入後則會寫回最後插入的編號，也永遠不會被更新。
+	autoIncrement bool
+}
+
+// skippable 會回傳欄位是否能夠在零值時被略過。
+func (f structField) skippable() bool {
+	return f.omitEmpty || f.autoIncrement
+}
+
+// defaultValue 是插入多筆資料時表示使用欄位預設值的參數，這會依照方言被建置成 `DEFAULT`。
+// 不支援 `DEFAULT` 的方言中，自動遞增的欄位會以 NULL 插入（SQLite 的 `INTEGER PRIMARY KEY` 會因此自動遞增）。
+type defaultValue struct {
+	autoIncrement bool
+}
+
+// structFieldsCache 以結構體型態快取了解析後的欄位。
+var structFieldsCache sync.Map
+
+// parseTag 會將 `db` 標籤拆分成欄位名稱與選項。
+func parseTag(tag string) (name string, options []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+// structFields 會依照宣告順序回傳結構體中所有對應到資料表格欄位的欄位，沒有標籤的匿名嵌入結構體會被展開。
+func structFields(t reflect.Type) []structField {
+	if v, ok := structFieldsCache.Load(t); ok {
+		return v.([]structField)
+	}
+	var fields []structField
+	structFieldsTraverse(&fields, map[string]bool{}, t, nil)
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// structFieldsTraverse 會遍歷結構體的欄位並且將其加入 `fields` 中，重複的欄位名稱只會保留第一個。
+func structFieldsTraverse(fields *[]structField, seen map[string]bool, t reflect.Type, head []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, options := parseTag(field.Tag.Get("db"))
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int{}, head...), i)
+		if field.Anonymous && tag == "" {
+			typ := field.Type
+			if typ.Kind() == reflect.Ptr {
+				// 未匯出的嵌入指標無法被取值。
+				if field.PkgPath != "" {
+					continue
+				}
+				typ = typ.Elem()
+			}
+			if typ.Kind() == reflect.Struct && !field.Type.Implements(typeValuer) {
+				structFieldsTraverse(fields, seen, typ, index)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		f := structField{column: tag, index: index}
+		for _, v := range options {
+			switch v {
+			case "omitempty":
+				f.omitEmpty = true
+			case "readonly":
+				f.readOnly = true
+			case "autoincrement":
+				f.autoIncrement = true
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// fieldByIndex 會回傳結構體中指定位置的欄位，如果途中的嵌入指標是 nil 則會回傳 false。
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for k, i := range index {
+		if k > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// fieldValue 會回傳欄位的值與是否為零值，無法取得的欄位會被視為 nil 與零值。
+func fieldValue(v reflect.Value, f structField) (interface{}, bool) {
+	field, ok := fieldByIndex(v, f.index)
+	if !ok {
+		return nil, true
+	}
+	return field.Interface(), field.IsZero()
+}
+
+// structValues 會將結構體、結構體指標或是它們的切片轉換成結構體的值，傳入的資料不是結構體時會回傳 false。
+func structValues(data interface{}) ([]reflect.Value, bool) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{v}, true
+	case reflect.Slice:
+		elem := v.Type().Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil, false
+		}
+		values := make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			for item.Kind() == reflect.Ptr {
+				if item.IsNil() {
+					return nil, false
+				}
+				item = item.Elem()
+			}
+			values = append(values, item)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// structInsertRows 會將結構體轉換成插入指令的欄位與每列資料。唯讀的欄位不會被插入，
+// 而 `omitempty` 與自動遞增的欄位在所有資料都是零值時會被略過，只有部分資料是零值時則會以 `defaultValue` 插入。
+func structInsertRows(values []reflect.Value) (columns []string, rows [][]interface{}) {
+	fields := structFields(values[0].Type())
+	rows = make([][]interface{}, len(values))
+	for _, f := range fields {
+		if f.readOnly {
+			continue
+		}
+		cells := make([]interface{}, len(values))
+		used := !f.skippable()
+		for k, v := range values {
+			value, zero := fieldValue(v, f)
+			if f.skippable() && zero {
+				cells[k] = defaultValue{autoIncrement: f.autoIncrement}
+				continue
+			}
+			cells[k] = value
+			used = true
+		}
+		if !used {
+			continue
+		}
+		columns = append(columns, f.column)
+		for k := range rows {
+			rows[k] = append(rows[k], cells[k])
+		}
+	}
+	return
+}
+
+// structUpdateColumns 會將結構體轉換成更新指令的欄位與資料。唯讀與自動遞增的欄位不會被更新，`omitempty` 的欄位則會在零值時被略過。
+func structUpdateColumns(v reflect.Value) (columns []string, values []interface{}) {
+	for _, f := range structFields(v.Type()) {
+		if f.readOnly || f.autoIncrement {
+			continue
+		}
+		value, zero := fieldValue(v, f)
+		if f.omitEmpty && zero {
+			continue
+		}
+		columns = append(columns, f.column)
+		values = append(values, value)
+	}
+	return
+}
+
+// assignInsertID 會將最後插入的編號寫回結構體中的自動遞增欄位。多筆資料會依照 MySQL 的行為，
+// 以第一筆資料的編號開始遞增寫回。只要有任何一筆資料已經指定了編號，就無法得知其他資料的編號，所以不會寫回。
+func assignInsertID(data interface{}, id int64) {
+	values, ok := structValues(data)
+	if !ok || len(values) == 0 {
+		return
+	}
+	var auto *structField
+	for _, f := range structFields(values[0].Type()) {
+		if f.autoIncrement && !f.readOnly {
+			auto = &f
+			break
+		}
+	}
+	if auto == nil {
+		return
+	}
+	targets := make([]reflect.Value, 0, len(values))
+	for _, v := range values {
+		field, ok := fieldByIndex(v, auto.index)
+		if !ok || !field.CanSet() {
+			return
+		}
+		if !field.IsZero() {
+			return
+		}
+		targets = append(targets, field)
+	}
+	for k, field := range targets {
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(id + int64(k))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(id + int64(k)))
+		}
+	}
+}
@@ -115,6 +115,38 @@ func (s *SubQuery) OrHaving(args ...interface{}) (subQuery *SubQuery) {
 	return
 }
 
+//=======================================================
+// 組合函式
+//=======================================================
+
+// Union 會以 `UNION` 將子指令的結果與目前子指令的結果組合在一起並移除重複的資料列。
+func (s *SubQuery) Union(subQueries ...*SubQuery) (subQuery *SubQuery) {
+	subQuery = s.clone()
+	subQuery.builder = subQuery.builder.Union(subQueries...)
+	return
+}
+
+// UnionAll 和 `Union` 相同，但會以 `UNION ALL` 保留重複的資料列。
+func (s *SubQuery) UnionAll(subQueries ...*SubQuery) (subQuery *SubQuery) {
+	subQuery = s.clone()
+	subQuery.builder = subQuery.builder.UnionAll(subQueries...)
+	return
+}
+
+// Intersect 會以 `INTERSECT` 僅保留同時存在於兩者結果中的資料列。
+func (s *SubQuery) Intersect(subQueries ...*SubQuery) (subQuery *SubQuery) {
+	subQuery = s.clone()
+	subQuery.builder = subQuery.builder.Intersect(subQueries...)
+	return
+}
+
+// Except 會以 `EXCEPT` 移除存在於子指令結果中的資料列。
+func (s *SubQuery) Except(subQueries ...*SubQuery) (subQuery *SubQuery) {
+	subQuery = s.clone()
+	subQuery.builder = subQuery.builder.Except(subQueries...)
+	return
+}
+
 //=======================================================
 // 加入函式
 //=======================================================
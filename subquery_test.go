@@ -148,3 +148,57 @@ func TestSubQueryJoinWhere(t *testing.T) {
 		Get("Users.Name", "Products.ProductName")
 	assertEqual(assert, "SELECT Users.Name, Products.ProductName FROM Products LEFT JOIN Users ON (Products.TenantID = Users.TenantID AND Users.Username = ?) RIGHT JOIN Posts ON (Products.TenantID = Posts.TenantID AND Posts.Username = ?)", subQuery.builder.Query())
 }
+
+func TestUnion(t *testing.T) {
+	assert := assert.New(t)
+	admins := builder.SubQuery().Table("Admins").Where("Level", 3).Get("Username")
+	banned := builder.SubQuery().Table("Banned").OrderBy("CreatedAt", "DESC").Limit(5).Get("Username")
+	builder, _ = builder.Table("Users").Where("Age", ">", 18).Union(admins).UnionAll(banned).OrderBy("Username", "ASC").Limit(10).Get("Username")
+	assert.Equal("SELECT Username FROM Users WHERE Age > ? UNION SELECT Username FROM Admins WHERE Level = ? UNION ALL (SELECT Username FROM Banned ORDER BY CreatedAt DESC LIMIT 5) ORDER BY Username ASC LIMIT 10", builder.Query())
+	assert.Equal([]interface{}{18, 3}, builder.Params())
+
+	builder, _ = builder.Table("Users").Intersect(admins).Except(banned).Get("Username")
+	assert.Equal("SELECT Username FROM Users INTERSECT SELECT Username FROM Admins WHERE Level = ? EXCEPT (SELECT Username FROM Banned ORDER BY CreatedAt DESC LIMIT 5)", builder.Query())
+	assert.Equal([]interface{}{3}, builder.Params())
+
+	// 子指令也能夠組合其他的子指令。
+	subQuery = builder.SubQuery().Table("Users").Where("ID", 1).Union(admins).Get("Username")
+	builder, _ = builder.Table("Posts").Where("Username", "IN", subQuery).Where("ID", 2).Get()
+	assert.Equal("SELECT * FROM Posts WHERE Username IN (SELECT Username FROM Users WHERE ID = ? UNION SELECT Username FROM Admins WHERE Level = ?) AND ID = ?", builder.Query())
+	assert.Equal([]interface{}{1, 3, 2}, builder.Params())
+}
+
+func TestClauseOrder(t *testing.T) {
+	assert := assert.New(t)
+	builder, _ = builder.Table("Users").Where("ID", ">", 1).GroupBy("Age").Having("Age", ">", 18).OrderBy("Age", "ASC").Limit(5).Get("Age")
+	assert.Equal("SELECT Age FROM Users WHERE ID > ? GROUP BY Age HAVING Age > ? ORDER BY Age ASC LIMIT 5", builder.Query())
+}
+
+func TestWith(t *testing.T) {
+	assert := assert.New(t)
+	active := builder.SubQuery().Table("Users").Where("Active", 1).Get("ID", "Username")
+	builder, _ = builder.With("ActiveUsers", active).Table("Posts").InnerJoin("ActiveUsers", "Posts.UserID = ActiveUsers.ID").Where("Posts.Title", "Hello").Get("ActiveUsers.Username")
+	assert.Equal("WITH ActiveUsers AS (SELECT ID, Username FROM Users WHERE Active = ?) SELECT ActiveUsers.Username FROM Posts INNER JOIN ActiveUsers ON (Posts.UserID = ActiveUsers.ID) WHERE Posts.Title = ?", builder.Query())
+	assert.Equal([]interface{}{1, "Hello"}, builder.Params())
+
+	// 公用資料表的參數會在 `SET` 的參數之前。
+	builder, _ = builder.With("ActiveUsers", active).Table("Users").Where("ID", "IN", builder.SubQuery().Table("ActiveUsers").Get("ID")).Update(map[string]interface{}{"Level": 2})
+	assert.Equal("WITH ActiveUsers AS (SELECT ID, Username FROM Users WHERE Active = ?) UPDATE Users SET Level = ? WHERE ID IN (SELECT ID FROM ActiveUsers)", builder.Query())
+	assert.Equal([]interface{}{1, 2}, builder.Params())
+	assert.False(isRead(builder.Query()))
+
+	builder, _ = builder.With("ActiveUsers", active).Table("Posts").Where("UserID", "NOT IN", builder.SubQuery().Table("ActiveUsers").Get("ID")).Delete()
+	assert.Equal("WITH ActiveUsers AS (SELECT ID, Username FROM Users WHERE Active = ?) DELETE FROM Posts WHERE UserID NOT IN (SELECT ID FROM ActiveUsers)", builder.Query())
+	assert.Equal([]interface{}{1}, builder.Params())
+}
+
+func TestWithRecursive(t *testing.T) {
+	assert := assert.New(t)
+	anchor := builder.SubQuery().Table("Categories").Where("ID", 1).Get("ID", "ParentID", "Name")
+	recursive := builder.SubQuery().Table("Categories AS c").InnerJoin("Tree AS t", "c.ParentID = t.ID").Where("c.Hidden", 0).Get("c.ID", "c.ParentID", "c.Name")
+	builder, _ = builder.With("Visible", builder.SubQuery().Table("Users").Where("Active", 1).Get("ID")).
+		WithRecursive("Tree", []string{"ID", "ParentID", "Name"}, anchor, recursive).
+		Table("Tree").Where("Name", "!=", "Root").Get()
+	assert.Equal("WITH RECURSIVE Visible AS (SELECT ID FROM Users WHERE Active = ?), Tree (ID, ParentID, Name) AS (SELECT ID, ParentID, Name FROM Categories WHERE ID = ? UNION ALL SELECT c.ID, c.ParentID, c.Name FROM Categories AS c INNER JOIN Tree AS t ON (c.ParentID = t.ID) WHERE c.Hidden = ?) SELECT * FROM Tree WHERE Name != ?", builder.Query())
+	assert.Equal([]interface{}{1, 1, 0, "Root"}, builder.Params())
+}
@@ -0,0 +1,215 @@
+package reiner
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlStateError 是帶有 SQLSTATE 的錯誤，用以模擬 PostgreSQL 驅動程式的錯誤。
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "pq: could not serialize access"
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func newTestTransactionBuilder(t *testing.T) *Builder {
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master"})
+	assert.NoError(t, err)
+	return b
+}
+
+func TestTransaction(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+
+	commits := atomic.LoadInt64(&txCommits)
+	err := b.Transaction(func(tx *Builder) error {
+		_, err := tx.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal(commits+1, atomic.LoadInt64(&txCommits))
+
+	rollbacks := atomic.LoadInt64(&txRollbacks)
+	errFailed := errors.New("failed")
+	err = b.Transaction(func(tx *Builder) error {
+		return errFailed
+	})
+	assert.Equal(errFailed, err)
+	assert.Equal(rollbacks+1, atomic.LoadInt64(&txRollbacks))
+
+	assert.PanicsWithValue("boom", func() {
+		b.Transaction(func(tx *Builder) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(rollbacks+2, atomic.LoadInt64(&txRollbacks))
+	assert.Equal(0, b.db.work.count)
+}
+
+func TestTransactionRetry(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+
+	var calls int
+	deadlock := func(tx *Builder) error {
+		calls++
+		if calls < 3 {
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		}
+		return nil
+	}
+	assert.Error(b.Transaction(deadlock))
+	assert.Equal(1, calls)
+
+	calls = 0
+	assert.NoError(b.SetRetryPolicy(RetryPolicy{MaxAttempts: 3}).Transaction(deadlock))
+	assert.Equal(3, calls)
+
+	calls = 0
+	err := b.SetRetryPolicy(RetryPolicy{MaxAttempts: 2}).Transaction(func(tx *Builder) error {
+		calls++
+		return sqlStateError("40001")
+	})
+	assert.Error(err)
+	assert.Equal(2, calls)
+
+	// 其他的錯誤不會重試。
+	calls = 0
+	b.SetRetryPolicy(RetryPolicy{MaxAttempts: 3}).Transaction(func(tx *Builder) error {
+		calls++
+		return &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	})
+	assert.Equal(1, calls)
+}
+
+func TestTransactionSavepoint(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+	preparedQueries.Lock()
+	preparedQueries.list = nil
+	preparedQueries.Unlock()
+
+	commits := atomic.LoadInt64(&txCommits)
+	rollbacks := atomic.LoadInt64(&txRollbacks)
+	tx, err := b.Begin()
+	assert.NoError(err)
+	nested, err := tx.Begin()
+	assert.NoError(err)
+	deeper, err := nested.Begin()
+	assert.NoError(err)
+	assert.NoError(deeper.Rollback())
+	assert.Equal(ErrUnbegunTransaction, deeper.Commit())
+	assert.NoError(nested.Commit())
+
+	// 巢狀的 `Transaction` 在發生錯誤時只會回溯到它的儲存點。
+	errFailed := errors.New("failed")
+	assert.Equal(errFailed, tx.Transaction(func(tx *Builder) error {
+		return errFailed
+	}))
+	assert.Equal(commits, atomic.LoadInt64(&txCommits))
+	assert.Equal(rollbacks, atomic.LoadInt64(&txRollbacks))
+	assert.NoError(tx.Commit())
+	assert.Equal(commits+1, atomic.LoadInt64(&txCommits))
+
+	preparedQueries.Lock()
+	defer preparedQueries.Unlock()
+	assert.Equal([]string{
+		"SAVEPOINT sp_1",
+		"SAVEPOINT sp_2",
+		"ROLLBACK TO SAVEPOINT sp_2",
+		"RELEASE SAVEPOINT sp_1",
+		"SAVEPOINT sp_1",
+		"ROLLBACK TO SAVEPOINT sp_1",
+	}, preparedQueries.list)
+}
+
+func TestTransactionOptions(t *testing.T) {
+	assert := assert.New(t)
+	b, err := NewWithConfig(Config{Dialect: testDialect{}, Master: "master", Slaves: []string{"slave"}})
+	assert.NoError(err)
+
+	tx, err := b.BeginTx(&sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.NoError(err)
+	assert.Equal("master", tx.db.getMaster().dataSourceName)
+	assert.Equal(driver.IsolationLevel(sql.LevelSerializable), lastTxOptions.Load().(driver.TxOptions).Isolation)
+	assert.NoError(tx.Commit())
+
+	// 唯讀交易會在 Slave 上執行。
+	tx, err = b.BeginTx(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	assert.NoError(err)
+	assert.Equal("slave", tx.db.getMaster().dataSourceName)
+	assert.True(lastTxOptions.Load().(driver.TxOptions).ReadOnly)
+	assert.NoError(tx.Rollback())
+
+	tx, err = b.UseMaster().BeginTx(&sql.TxOptions{ReadOnly: true})
+	assert.NoError(err)
+	assert.Equal("master", tx.db.getMaster().dataSourceName)
+	assert.NoError(tx.Rollback())
+
+	// 唯讀模式中仍然能夠開始唯讀交易。
+	b.db.setReadOnly(true)
+	_, err = b.Begin()
+	assert.Equal(ErrReadOnly, err)
+	tx, err = b.BeginTx(&sql.TxOptions{ReadOnly: true})
+	assert.NoError(err)
+	assert.NoError(tx.Rollback())
+}
+
+func TestTransactionHooks(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+	assert.Equal(ErrUnbegunTransaction, b.OnCommit(func() {}))
+
+	var events []string
+	hook := func(event string) func() {
+		return func() {
+			events = append(events, event)
+		}
+	}
+	tx, err := b.Begin()
+	assert.NoError(err)
+	assert.NoError(tx.OnCommit(hook("commit 1")))
+	assert.NoError(tx.OnRollback(hook("rollback 1")))
+
+	// 巢狀交易的函式會在提交之後交給外層交易。
+	nested, _ := tx.Begin()
+	assert.NoError(nested.OnCommit(hook("nested commit")))
+	assert.NoError(nested.Commit())
+	// 被回溯的巢狀交易會立即執行它的回溯函式。
+	nested, _ = tx.Begin()
+	assert.NoError(nested.OnCommit(hook("discarded")))
+	assert.NoError(nested.OnRollback(hook("nested rollback")))
+	assert.NoError(nested.Rollback())
+	assert.Equal([]string{"nested rollback"}, events)
+
+	assert.NoError(tx.OnCommit(hook("commit 2")))
+	assert.NoError(tx.Commit())
+	assert.Equal([]string{"nested rollback", "commit 1", "nested commit", "commit 2"}, events)
+
+	// 提交失敗時不會執行任何的函式。
+	events = nil
+	tx, _ = b.Begin()
+	tx.OnCommit(hook("commit"))
+	tx.OnRollback(hook("rollback"))
+	atomic.StoreInt32(&txCommitFails, 1)
+	assert.Error(tx.Commit())
+	atomic.StoreInt32(&txCommitFails, 0)
+	assert.Empty(events)
+
+	tx, _ = b.Begin()
+	tx.OnCommit(hook("commit"))
+	tx.OnRollback(hook("rollback"))
+	assert.NoError(tx.Rollback())
+	assert.Equal([]string{"rollback"}, events)
+}
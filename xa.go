@@ -0,0 +1,160 @@
+package reiner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+)
+
+// xaState 是 XA 交易分支目前的狀態。
+type xaState int
+
+const (
+	// xaActive 表示已經透過 `XA START` 開始，能夠執行指令。
+	xaActive xaState = iota
+	// xaIdle 表示已經透過 `XA END` 結束執行指令，等待準備或提交。
+	xaIdle
+	// xaPrepared 表示已經透過 `XA PREPARE` 準備好提交。
+	xaPrepared
+)
+
+// xaBranch 是一段固定在單個連線上的 XA 交易分支。
+type xaBranch struct {
+	xid   string
+	conn  *sql.Conn
+	state xaState
+	// done 會在 XA 交易結束時被呼叫，表示這段交易已經不再需要等待。
+	done func()
+}
+
+// xidLiteral 會將 XA 交易編號轉換成十六進位的字面值，避免編號中的字元破壞 SQL 指令。
+func xidLiteral(xid string) string {
+	return "X'" + hex.EncodeToString([]byte(xid)) + "'"
+}
+
+// beginXA 會從主要資料庫的連線池中取出單個連線並在上面開始一段 XA 交易，回傳的資料庫來源副本中的指令都會在這個連線上執行。
+func (d *DB) beginXA(ctx context.Context, xid string) (*DB, error) {
+	if d.isReadOnly() {
+		return nil, ErrReadOnly
+	}
+	done, err := d.work.add()
+	if err != nil {
+		return nil, err
+	}
+	master := d.getMaster()
+	conn, err := master.db.Conn(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	if _, err = conn.ExecContext(ctx, "XA START "+xidLiteral(xid)); err != nil {
+		conn.Close()
+		done()
+		return nil, err
+	}
+	newDB := d.withTransaction(master, nil, nil)
+	newDB.master.conn = conn
+	newDB.xa = &xaBranch{xid: xid, conn: conn, state: xaActive, done: master.trackTx(done)}
+	return newDB, nil
+}
+
+// endXA 會以 `XA END` 結束在這段 XA 交易中執行指令。
+func (d *DB) endXA(ctx context.Context) error {
+	if d.xa == nil || d.xa.conn == nil {
+		return ErrUnbegunTransaction
+	}
+	if d.xa.state != xaActive {
+		return nil
+	}
+	if _, err := d.xa.conn.ExecContext(ctx, "XA END "+xidLiteral(d.xa.xid)); err != nil {
+		return d.failXA(err)
+	}
+	d.xa.state = xaIdle
+	return nil
+}
+
+// prepareXA 會以 `XA PREPARE` 準備提交這段 XA 交易，尚未結束的 XA 交易會先被結束。
+func (d *DB) prepareXA(ctx context.Context) error {
+	if err := d.endXA(ctx); err != nil {
+		return err
+	}
+	if _, err := d.xa.conn.ExecContext(ctx, "XA PREPARE "+xidLiteral(d.xa.xid)); err != nil {
+		return d.failXA(err)
+	}
+	d.xa.state = xaPrepared
+	return nil
+}
+
+// finishXA 會以 `XA COMMIT` 或 `XA ROLLBACK` 結束這段 XA 交易並且歸還所固定的連線。
+// 沒有經過準備的 XA 交易在提交時會以 `ONE PHASE` 直接提交。連線中斷時連線也會被歸還，
+// 已經準備好的 XA 交易則能夠在之後透過 `resolveXA` 結束。
+func (d *DB) finishXA(ctx context.Context, commit bool) error {
+	if err := d.endXA(ctx); err != nil {
+		return err
+	}
+	query := "XA ROLLBACK " + xidLiteral(d.xa.xid)
+	if commit {
+		query = "XA COMMIT " + xidLiteral(d.xa.xid)
+		if d.xa.state != xaPrepared {
+			query += " ONE PHASE"
+		}
+	}
+	if _, err := d.xa.conn.ExecContext(ctx, query); err != nil {
+		return d.failXA(err)
+	}
+	d.releaseXA()
+	return nil
+}
+
+// failXA 會在發生連線層級的錯誤時歸還這段 XA 交易所固定的連線，因為這個連線已經無法再執行任何指令，
+// 否則連線會被保留，讓呼叫者能夠重試或回溯。回傳的是傳入的錯誤。
+func (d *DB) failXA(err error) error {
+	if isConnectionFailure(err) || errors.Is(err, sql.ErrConnDone) {
+		d.releaseXA()
+	}
+	return err
+}
+
+// releaseXA 會歸還這段 XA 交易所固定的連線並且通知工作追蹤器這段交易已經結束。
+func (d *DB) releaseXA() {
+	d.xa.conn.Close()
+	d.xa.conn = nil
+	d.master.conn = nil
+	d.xa.done()
+}
+
+// resolveXA 會以 `XA COMMIT` 或 `XA ROLLBACK` 結束一段已經準備好的 XA 交易，這能夠在任何連線上執行。
+func (d *DB) resolveXA(ctx context.Context, xid string, commit bool) error {
+	if d.isReadOnly() {
+		return ErrReadOnly
+	}
+	query := "XA ROLLBACK " + xidLiteral(xid)
+	if commit {
+		query = "XA COMMIT " + xidLiteral(xid)
+	}
+	_, err := d.getMaster().db.ExecContext(ctx, query)
+	return err
+}
+
+// recoverXA 會透過 `XA RECOVER` 列出主要資料庫上所有已經準備好但尚未提交或回溯的 XA 交易編號。
+func (d *DB) recoverXA(ctx context.Context) ([]string, error) {
+	rows, err := d.getMaster().db.QueryContext(ctx, "XA RECOVER")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var xids []string
+	for rows.Next() {
+		var formatID, gtridLength, bqualLength int
+		var data []byte
+		if err := rows.Scan(&formatID, &gtridLength, &bqualLength, &data); err != nil {
+			return nil, err
+		}
+		if gtridLength > len(data) {
+			gtridLength = len(data)
+		}
+		xids = append(xids, string(data[:gtridLength]))
+	}
+	return xids, rows.Err()
+}
@@ -0,0 +1,74 @@
+package reiner
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXA(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+	preparedQueries.Lock()
+	preparedQueries.list = nil
+	preparedQueries.Unlock()
+
+	assert.Equal(ErrUnbegunTransaction, b.XAPrepare())
+	assert.Equal(ErrUnbegunTransaction, b.XACommit())
+
+	tx, err := b.XABegin("order-1")
+	assert.NoError(err)
+	assert.Equal(1, b.db.work.count)
+	_, err = tx.Table("Users").Update(map[string]interface{}{"Username": "YamiOdymel"})
+	assert.NoError(err)
+	_, err = tx.Begin()
+	assert.Equal(ErrXATransaction, err)
+	assert.NoError(tx.XAPrepare())
+	assert.NoError(tx.XACommit())
+	assert.Equal(ErrUnbegunTransaction, tx.XACommit())
+	assert.Equal(0, b.db.work.count)
+
+	// 沒有經過準備的 XA 交易會以單階段提交。
+	tx, err = b.XABegin("order-2")
+	assert.NoError(err)
+	assert.NoError(tx.XACommit())
+	tx, err = b.XABegin("order-3")
+	assert.NoError(err)
+	assert.NoError(tx.XARollback())
+	assert.NoError(b.XACommitPrepared("order-4"))
+
+	preparedQueries.Lock()
+	defer preparedQueries.Unlock()
+	assert.Equal([]string{
+		"XA START X'6f726465722d31'",
+		"UPDATE Users SET Username = ?",
+		"XA END X'6f726465722d31'",
+		"XA PREPARE X'6f726465722d31'",
+		"XA COMMIT X'6f726465722d31'",
+		"XA START X'6f726465722d32'",
+		"XA END X'6f726465722d32'",
+		"XA COMMIT X'6f726465722d32' ONE PHASE",
+		"XA START X'6f726465722d33'",
+		"XA END X'6f726465722d33'",
+		"XA ROLLBACK X'6f726465722d33'",
+		"XA COMMIT X'6f726465722d34'",
+	}, preparedQueries.list)
+}
+
+func TestXAConnectionFailure(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestTransactionBuilder(t)
+
+	tx, err := b.XABegin("order-5")
+	assert.NoError(err)
+	assert.NoError(tx.XAPrepare())
+	atomic.StoreInt32(&xaCommitFails, 1)
+	assert.Error(tx.XACommit())
+	atomic.StoreInt32(&xaCommitFails, 0)
+
+	// 連線中斷後固定的連線會被歸還，已經準備好的交易仍然能夠在其他連線上提交。
+	assert.Equal(0, b.db.work.count)
+	assert.Equal(ErrUnbegunTransaction, tx.XACommit())
+	assert.NoError(b.XACommitPrepared("order-5"))
+}